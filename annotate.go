@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Result annotations -----------
+
+// annotations are derived fields added to successful results before output.
+type annotations struct {
+	coords   string // "decimal", "dms" or "utm"
+	geohash  int    // geohash length, 0 to omit
+	pluscode bool
+}
+
+// apply fills the requested derived fields on every successful result.
+func (a annotations) apply(results []geocode.GeocodeResult) {
+	for i := range results {
+		a.applyOne(&results[i])
+	}
+}
+
+func (a annotations) applyOne(r *geocode.GeocodeResult) {
+	if r.Error != "" || r.Unresolved {
+		return
+	}
+	switch a.coords {
+	case "dms":
+		r.LatitudeDMS = geocode.FormatDMS(r.Latitude, true)
+		r.LongitudeDMS = geocode.FormatDMS(r.Longitude, false)
+	case "utm":
+		if u, ok := geocode.ToUTM(r.Latitude, r.Longitude); ok {
+			r.UTM = &u
+		}
+	}
+	if a.geohash > 0 {
+		r.Geohash = geocode.EncodeGeohash(r.Latitude, r.Longitude, a.geohash)
+	}
+	if a.pluscode {
+		r.PlusCode = geocode.EncodePlusCode(r.Latitude, r.Longitude)
+	}
+}
+
+// optionalInt is an int flag that may be given without a value, in which
+// case it takes def: --geohash means precision 9, --geohash=6 means 6.
+type optionalInt struct {
+	value int
+	def   int
+}
+
+func (o *optionalInt) String() string {
+	if o == nil || o.value == 0 {
+		return ""
+	}
+	return strconv.Itoa(o.value)
+}
+
+func (o *optionalInt) Set(s string) error {
+	switch s {
+	case "true":
+		o.value = o.def
+		return nil
+	case "false":
+		o.value = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("expected a positive integer, got %q", s)
+	}
+	o.value = n
+	return nil
+}
+
+func (o *optionalInt) IsBoolFlag() bool { return true }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Batch -----------
+
+// readLines returns the non-blank, trimmed lines of the file at path.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// batchWindow is how many distinct lines per worker may be in flight or
+// waiting to be emitted at once.
+const batchWindow = 4
+
+// geocodeBatch resolves every line using up to concurrency parallel workers
+// and passes each line's results to emit in input order, as soon as that
+// line and all before it are done. A line that cannot be resolved yields a
+// result carrying an error instead of aborting the run.
+//
+// Lines with the same key (see geocode.QueryKey) are resolved once and their
+// results repeated for every occurrence, with Address set to that line.
+//
+// Workers resolve through the same client and rate limiters, so --qps bounds
+// the whole pool. They run at most batchWindow lines per worker ahead of the
+// oldest unfinished line, which keeps a single slow address from letting
+// finished results pile up unboundedly on large inputs.
+//
+// Cancelling ctx stops dispatching new lines; resolve is expected to abort
+// in-flight ones through the same context. Emitted output then ends at the
+// last line finished before the cancellation, and ctx's error is returned.
+func geocodeBatch(ctx context.Context, lines []string, concurrency int, key func(string) string, resolve func(string) ([]geocode.GeocodeResult, error), emit func([]geocode.GeocodeResult)) error {
+	// unique holds the first occurrence of each distinct line; slot maps
+	// every line to its entry in unique and last to its final occurrence.
+	var unique []string
+	slot := make([]int, len(lines))
+	index := make(map[string]int)
+	for i, line := range lines {
+		k := key(line)
+		u, ok := index[k]
+		if !ok {
+			u = len(unique)
+			index[k] = u
+			unique = append(unique, line)
+		}
+		slot[i] = u
+	}
+	last := make([]int, len(unique))
+	for i, u := range slot {
+		last[u] = i
+	}
+
+	out := make([][]geocode.GeocodeResult, len(unique))
+	done := make([]chan struct{}, len(unique))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	jobs := make(chan int)
+	window := make(chan struct{}, concurrency*batchWindow)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				results, err := resolve(unique[u])
+				if err != nil {
+					results = []geocode.GeocodeResult{{Address: unique[u], Error: err.Error()}}
+				}
+				out[u] = results
+				close(done[u])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for u := range unique {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	next := 0 // next entry of unique to be emitted for the first time
+	for i, u := range slot {
+		select {
+		case <-done[u]:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		results := out[u]
+		if unique[u] != lines[i] {
+			results = append([]geocode.GeocodeResult(nil), results...)
+			for j := range results {
+				results[j].Address = lines[i]
+			}
+		}
+		emit(results)
+		if u == next {
+			next++
+			<-window
+		}
+		if i == last[u] {
+			out[u] = nil
+		}
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Compare -----------
+
+// compareResult is the output of --compare: two providers' answers for the
+// same address, how far apart they are, and where their addresses differ.
+type compareResult struct {
+	Address   string                   `json:"address"`
+	Answers   []geocode.ProviderAnswer `json:"answers"`
+	DistanceM *float64                 `json:"distance_m,omitempty"`
+	// Differences lists the formatted address and every component the two
+	// providers disagree on, including ones only one of them reports.
+	Differences []componentDiff `json:"differences,omitempty"`
+}
+
+type componentDiff struct {
+	Component string `json:"component"`
+	A         string `json:"a"`
+	B         string `json:"b"`
+}
+
+// parseCompare parses a --compare value into exactly two provider names.
+func parseCompare(s string) ([]string, error) {
+	names := strings.Split(s, ",")
+	if len(names) != 2 {
+		return nil, fmt.Errorf("--compare takes exactly two providers, e.g. google,osm")
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if _, ok := geocode.LookupProvider(names[i]); !ok {
+			return nil, fmt.Errorf("--compare: unknown provider %q", names[i])
+		}
+	}
+	if names[0] == names[1] {
+		return nil, fmt.Errorf("--compare needs two different providers")
+	}
+	return names, nil
+}
+
+// compare builds the comparison of two answers. Distance and differences
+// are only reported when both providers succeeded.
+func compare(address string, answers []geocode.ProviderAnswer) compareResult {
+	res := compareResult{Address: address, Answers: answers}
+	a, b := answers[0].Result, answers[1].Result
+	if a == nil || b == nil {
+		return res
+	}
+	d := geocode.Haversine(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+	res.DistanceM = &d
+
+	if a.Formatted != b.Formatted {
+		res.Differences = append(res.Differences, componentDiff{"formatted", a.Formatted, b.Formatted})
+	}
+	keys := make(map[string]bool)
+	for k := range a.Components {
+		keys[k] = true
+	}
+	for k := range b.Components {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		if a.Components[k] != b.Components[k] {
+			res.Differences = append(res.Differences, componentDiff{k, a.Components[k], b.Components[k]})
+		}
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Config file -----------
+
+// Config is the on-disk provider configuration loaded via --config.
+type Config struct {
+	Providers []ProviderConfig `json:"providers"`
+}
+
+// ProviderConfig enables or disables a single provider. Providers are tried
+// in the order they are listed; Enabled defaults to true when omitted.
+// Weight is the provider's trust in --consensus (default 1); see
+// geocode.WeightedConsensus.
+type ProviderConfig struct {
+	Name    string   `json:"name"`
+	Enabled *bool    `json:"enabled,omitempty"`
+	Weight  *float64 `json:"weight,omitempty"`
+}
+
+// loadConfig reads a JSON config file and returns the names of the enabled
+// providers in the configured order, along with any consensus weights
+// (nil if none are set).
+func loadConfig(path string) ([]string, map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+
+	var ordered []string
+	var weights map[string]float64
+	seen := make(map[string]bool)
+	for _, pc := range cfg.Providers {
+		if _, ok := geocode.LookupProvider(pc.Name); !ok {
+			return nil, nil, fmt.Errorf("%s: unknown provider %q", path, pc.Name)
+		}
+		if seen[pc.Name] {
+			return nil, nil, fmt.Errorf("%s: provider %q listed more than once", path, pc.Name)
+		}
+		seen[pc.Name] = true
+		if pc.Weight != nil {
+			if !(*pc.Weight > 0) || math.IsInf(*pc.Weight, 0) {
+				return nil, nil, fmt.Errorf("%s: provider %q weight must be a positive number", path, pc.Name)
+			}
+			if weights == nil {
+				weights = make(map[string]float64)
+			}
+			weights[pc.Name] = *pc.Weight
+		}
+		if pc.Enabled != nil && !*pc.Enabled {
+			continue
+		}
+		ordered = append(ordered, pc.Name)
+	}
+	if len(ordered) == 0 {
+		return nil, nil, fmt.Errorf("%s: no providers enabled", path)
+	}
+	return ordered, weights, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- CSV input -----------
+
+// readCSV reads the CSV file at path and returns its header, its rows and
+// the index of the named address column.
+func readCSV(path, column string) ([]string, [][]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, 0, fmt.Errorf("%s: missing header row", path)
+	}
+	header := records[0]
+	for i, name := range header {
+		if name == column {
+			return header, records[1:], i, nil
+		}
+	}
+	return nil, nil, 0, fmt.Errorf("%s: no column named %q", path, column)
+}
+
+// geocodeCSV geocodes the address column of every row and writes the rows
+// to w with latitude, longitude, provider and error columns appended. Rows
+// that fail keep their original cells, with empty coordinates and the
+// error filled in. If ctx is cancelled, the rows finished so far are still
+// flushed; see geocodeBatch.
+func geocodeCSV(ctx context.Context, w io.Writer, header []string, rows [][]string, col, concurrency, round int, key func(string) string, resolve func(string) ([]geocode.GeocodeResult, error)) error {
+	addresses := make([]string, len(rows))
+	for i, row := range rows {
+		if col < len(row) {
+			addresses[i] = row[col]
+		}
+	}
+	resolveCell := func(address string) ([]geocode.GeocodeResult, error) {
+		if address == "" {
+			return nil, fmt.Errorf("empty address")
+		}
+		return resolve(address)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(append(header[:len(header):len(header)], "latitude", "longitude", "provider", "error"))
+	i := 0
+	err := geocodeBatch(ctx, addresses, concurrency, key, resolveCell, func(results []geocode.GeocodeResult) {
+		r := results[0]
+		row := rows[i]
+		i++
+		if r.Error != "" {
+			cw.Write(append(row[:len(row):len(row)], "", "", "", r.Error))
+			return
+		}
+		roundResult(&r, round)
+		cw.Write(append(row[:len(row):len(row)], formatFloat(r.Latitude), formatFloat(r.Longitude), r.Provider, ""))
+	})
+	cw.Flush()
+	if werr := cw.Error(); werr != nil {
+		return werr
+	}
+	return err
+}
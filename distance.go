@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Distance -----------
+
+// distanceResult is the output of the distance subcommand.
+type distanceResult struct {
+	From       geocode.GeocodeResult `json:"from"`
+	To         geocode.GeocodeResult `json:"to"`
+	DistanceM  float64               `json:"distance_m"`
+	DistanceKm float64               `json:"distance_km"`
+}
+
+// distance resolves both addresses and returns the great-circle distance
+// between them.
+func distance(from, to string, resolve func(string) ([]geocode.GeocodeResult, error)) (distanceResult, error) {
+	a, err := resolve(from)
+	if err != nil {
+		return distanceResult{}, err
+	}
+	b, err := resolve(to)
+	if err != nil {
+		return distanceResult{}, err
+	}
+	d := geocode.Haversine(a[0].Latitude, a[0].Longitude, b[0].Latitude, b[0].Longitude)
+	return distanceResult{From: a[0], To: b[0], DistanceM: d, DistanceKm: d / 1000}, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ----------- .env file -----------
+
+// loadDotEnv reads KEY=value lines from path and sets each variable that is
+// not already present in the environment, so real variables always win.
+// Blank lines and lines starting with # are ignored; values may be wrapped
+// in single or double quotes.
+func loadDotEnv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+		value, err := parseDotEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseDotEnvValue unquotes a value. Double-quoted values support Go escape
+// sequences, single-quoted values are taken literally, and any value may be
+// followed by an inline " #" comment.
+func parseDotEnvValue(v string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(v, `"`):
+		quoted, err := strconv.QuotedPrefix(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", v)
+		}
+		value, _ = strconv.Unquote(quoted)
+		rest = v[len(quoted):]
+	case strings.HasPrefix(v, "'"):
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", v)
+		}
+		value, rest = v[1:end+1], v[end+2:]
+	default:
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = strings.TrimSpace(v[:i])
+		}
+		return v, nil
+	}
+	// Only a comment may follow the closing quote.
+	if trimmed := strings.TrimSpace(rest); trimmed != "" && (!strings.HasPrefix(trimmed, "#") || trimmed == rest) {
+		return "", fmt.Errorf("unexpected text after quoted value %s", v)
+	}
+	return value, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestParseDotEnvValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{`plain`, "plain", false},
+		{`plain # comment`, "plain", false},
+		{`"quoted"`, "quoted", false},
+		{`"quoted" # comment`, "quoted", false},
+		{`"a # b"`, "a # b", false},
+		{`"tab\tseparated"`, "tab\tseparated", false},
+		{`'single'`, "single", false},
+		{`'single' # comment`, "single", false},
+		{`'no \t escapes'`, `no \t escapes`, false},
+		{`"unterminated`, "", true},
+		{`'unterminated`, "", true},
+		{`"quoted" trailing`, "", true},
+		{`"quoted"# no space`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDotEnvValue(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDotEnvValue(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDotEnvValue(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Explain -----------
+
+// explain writes the providers a lookup would try, in order, followed by
+// every provider that would be skipped and why. It makes no requests.
+func explain(w io.Writer, ordered []string, opts geocode.Options, reverse bool) {
+	tried := make(map[string]bool)
+	var skipped []string
+
+	fmt.Fprintln(w, "Providers will be tried in this order:")
+	n := 0
+	for _, name := range ordered {
+		p, ok := geocode.LookupProvider(name)
+		if !ok {
+			continue
+		}
+		tried[name] = true
+		switch {
+		case p.NeedsKey() && opts.Key(p) == "":
+			skipped = append(skipped, fmt.Sprintf("%s: no API key (set --%s-key or %s)", name, name, p.KeyEnv))
+		case reverse && !p.SupportsReverse():
+			skipped = append(skipped, fmt.Sprintf("%s: does not support reverse geocoding", name))
+		case opts.RequireHTTPS && opts.PlainHTTPEndpoint(p) != "":
+			skipped = append(skipped, fmt.Sprintf("%s: plain HTTP endpoint %s refused (--require-https)", name, opts.PlainHTTPEndpoint(p)))
+		default:
+			n++
+			fmt.Fprintf(w, "  %d. %s\n", n, name)
+		}
+	}
+	if n == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+
+	for _, p := range geocode.Providers() {
+		if !tried[p.Name] {
+			skipped = append(skipped, fmt.Sprintf("%s: not in the provider list (--config, --providers or $GEOLOOKER_PROVIDERS)", p.Name))
+		}
+	}
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Skipped:")
+	for _, s := range skipped {
+		fmt.Fprintf(w, "  %s\n", s)
+	}
+}
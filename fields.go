@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Output fields -----------
+
+// resultFields lists the JSON field names of a result in struct order.
+func resultFields() []string {
+	var names []string
+	t := reflect.TypeOf(geocode.GeocodeResult{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// outputFields lists the fields --output-fields accepts for format: the
+// JSON field names, or the CSV column names for csv.
+func outputFields(format string) []string {
+	if format != "csv" {
+		return resultFields()
+	}
+	var names []string
+	for _, c := range csvColumns {
+		names = append(names, c.name)
+	}
+	return append(names, "error")
+}
+
+// parseOutputFields parses a comma-separated --output-fields value for
+// format, rejecting duplicates and names format does not have.
+func parseOutputFields(s, format string) ([]string, error) {
+	valid := outputFields(format)
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(valid, f) {
+			return nil, fmt.Errorf("unknown output field %q for %s; valid fields: %s", f, format, strings.Join(valid, ", "))
+		}
+		if slices.Contains(fields, f) {
+			return nil, fmt.Errorf("output field %q listed twice", f)
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("--output-fields lists no fields")
+	}
+	return fields, nil
+}
+
+// fieldObject is a JSON object restricted to a set of keys, written in the
+// order they were requested.
+type fieldObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o fieldObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	n := 0
+	for _, k := range o.keys {
+		v, ok := o.values[k]
+		if !ok {
+			continue
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(v)
+		n++
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// projectResult keeps only fields of r. Empty fields are still omitted as
+// usual, and a batch row's error is always kept so failures stay visible.
+func projectResult(r geocode.GeocodeResult, fields []string) (fieldObject, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fieldObject{}, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fieldObject{}, err
+	}
+	keys := fields
+	if !slices.Contains(keys, "error") {
+		keys = append(keys[:len(keys):len(keys)], "error")
+	}
+	return fieldObject{keys: keys, values: values}, nil
+}
+
+// projectResults applies projectResult to every result.
+func projectResults(results []geocode.GeocodeResult, fields []string) ([]fieldObject, error) {
+	out := make([]fieldObject, len(results))
+	for i, r := range results {
+		o, err := projectResult(r, fields)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = o
+	}
+	return out, nil
+}
@@ -0,0 +1,137 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// autocompleters holds the providers with a dedicated suggestion endpoint.
+// Other providers answer autocomplete lookups with their normal search.
+// Mapbox's search endpoint already matches partial input (autocomplete is
+// on by default), so its normal search is used as is.
+var autocompleters = map[string]func(context.Context, *client, string, int) ([]GeocodeResult, error){
+	"google":     autocompleteGoogle,
+	"mapbox":     geocodeMapbox,
+	"locationiq": autocompleteLocationIQ,
+}
+
+// SupportsAutocomplete reports whether the provider has a suggestion
+// endpoint for partial input, rather than answering with its normal search.
+func (p Provider) SupportsAutocomplete() bool {
+	return autocompleters[p.Name] != nil
+}
+
+// Autocomplete returns up to opts.Limit suggestions for partial input from
+// the first provider that succeeds, best first. Each suggestion's Formatted
+// field holds the candidate description.
+func Autocomplete(ctx context.Context, input string, opts Options) ([]GeocodeResult, error) {
+	c := newClient(opts)
+	results, err := c.run(ctx, lookupRequest{address: NormalizeAddress(input, false), autocomplete: true})
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Address = input
+	}
+	return results, nil
+}
+
+type GoogleAutocompleteResponse struct {
+	Predictions []struct {
+		Description string `json:"description"`
+		PlaceID     string `json:"place_id"`
+	} `json:"predictions"`
+	Status string `json:"status"`
+}
+
+type GooglePlaceDetailsResponse struct {
+	Result struct {
+		Geometry struct {
+			Location struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"result"`
+	Status string `json:"status"`
+}
+
+// autocompleteGoogle uses Places Autocomplete. Predictions carry no
+// coordinates, so each one is resolved with a Place Details request, except
+// under Options.SessionToken: there each suggestion is Unresolved, with only
+// its PlaceID, and the caller resolves the chosen one with GeocodePlace to end the
+// session.
+func autocompleteGoogle(ctx context.Context, c *client, input string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return nil, missingKey("GOOGLE_API_KEY")
+	}
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/place/autocomplete/json")
+	query := fmt.Sprintf("%s?input=%s&key=%s", endpoint, url.QueryEscape(input), apiKey)
+	if c.opts.Country != "" {
+		query += "&components=" + url.QueryEscape("country:"+c.opts.Country)
+	}
+	query += c.langParams("google")
+	query += c.sessionParams()
+	var result GoogleAutocompleteResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Status != "OK" {
+		return nil, googleStatusError(result.Status)
+	}
+
+	var results []GeocodeResult
+	for i, p := range result.Predictions {
+		if i == limit {
+			break
+		}
+		if c.opts.SessionToken != "" {
+			res := newResult("google", input, 0, 0)
+			res.Formatted = p.Description
+			res.PlaceID = p.PlaceID
+			res.Unresolved = true
+			results = append(results, res)
+			continue
+		}
+		endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/place/details/json")
+		query := fmt.Sprintf("%s?place_id=%s&fields=geometry&key=%s", endpoint, url.QueryEscape(p.PlaceID), apiKey)
+		var details GooglePlaceDetailsResponse
+		if err := c.getJSON(ctx, query, nil, &details); err != nil {
+			return nil, err
+		}
+		if details.Status != "OK" {
+			return nil, googleStatusError(details.Status)
+		}
+		loc := details.Result.Geometry.Location
+		lat, lng, err := requireCoords(loc.Lat, loc.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("google", input, lat, lng)
+		res.Formatted = p.Description
+		res.PlaceID = p.PlaceID
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return results, nil
+}
+
+func autocompleteLocationIQ(ctx context.Context, c *client, input string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("locationiq", "LOCATIONIQ_KEY")
+	if apiKey == "" {
+		return nil, missingKey("LOCATIONIQ_KEY")
+	}
+	endpoint := c.endpoint("locationiq", "https://api.locationiq.com", "/v1/autocomplete")
+	query := fmt.Sprintf("%s?key=%s&q=%s&limit=%d", endpoint, apiKey, url.QueryEscape(input), limit)
+	query += c.biasParams("locationiq")
+	query += c.langParams("locationiq")
+	var result LocationIQResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	return locationIQResults(input, result, limit)
+}
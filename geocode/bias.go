@@ -0,0 +1,181 @@
+package geocode
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Bounds is a bounding box used to bias or restrict forward lookups.
+type Bounds struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// biasParams returns the query parameters (each prefixed with '&') that
+// express Options.Country, Options.Region, Options.Bounds and
+// Options.FeatureType in the given provider's native API. Constraints a
+// provider cannot express are silently dropped:
+//
+//	provider      Country           Region Bounds                FeatureType
+//	google        components        region bounds (bias)         -
+//	osm           countrycodes      -      viewbox (restrict)    featuretype
+//	locationiq    countrycodes      -      viewbox (restrict)    -
+//	positionstack country           -      -                     -
+//	opencage      countrycode       -      bounds                -
+//	mapquest      -                 -      boundingBox           -
+//	mapbox        country           -      bbox                  -
+//	here          -                 -      in=bbox               -
+//	tomtom        countrySet        -      topLeft/btmRight      -
+//	bing          userRegion (bias) -      userMapView (bias)    -
+//	geonames      country           -      north/south/east/west -
+//	photon        -                 -      bbox                  layer
+//	pelias        boundary.country  -      boundary.rect         -
+//	yandex        -                 -      bbox (restrict)       -
+func (c *client) biasParams(provider string) string {
+	country := strings.ToLower(c.opts.Country)
+	b := c.opts.Bounds
+
+	var params []string
+	add := func(format string, args ...any) {
+		params = append(params, fmt.Sprintf(format, args...))
+	}
+	switch provider {
+	case "google":
+		if country != "" {
+			add("components=%s", url.QueryEscape("country:"+country))
+		}
+		if c.opts.Region != "" {
+			add("region=%s", url.QueryEscape(strings.ToLower(c.opts.Region)))
+		}
+		if b != nil {
+			add("bounds=%s", url.QueryEscape(fmt.Sprintf("%g,%g|%g,%g", b.MinLat, b.MinLng, b.MaxLat, b.MaxLng)))
+		}
+	case "osm", "locationiq":
+		if country != "" {
+			add("countrycodes=%s", url.QueryEscape(country))
+		}
+		if b != nil {
+			add("viewbox=%g,%g,%g,%g&bounded=1", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+		if provider == "osm" && c.opts.FeatureType != "" {
+			add("featuretype=%s", url.QueryEscape(c.opts.FeatureType))
+		}
+	case "positionstack":
+		if country != "" {
+			add("country=%s", url.QueryEscape(strings.ToUpper(country)))
+		}
+	case "opencage":
+		if country != "" {
+			add("countrycode=%s", url.QueryEscape(country))
+		}
+		if b != nil {
+			add("bounds=%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+	case "mapquest":
+		if b != nil {
+			// Upper-left corner first, then lower-right.
+			add("boundingBox=%g,%g,%g,%g", b.MaxLat, b.MinLng, b.MinLat, b.MaxLng)
+		}
+	case "mapbox":
+		if country != "" {
+			add("country=%s", url.QueryEscape(country))
+		}
+		if b != nil {
+			add("bbox=%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+	case "here":
+		// HERE only accepts ISO 3166-1 alpha-3 country codes, so only the
+		// bounding box is passed through.
+		if b != nil {
+			add("in=bbox:%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+	case "tomtom":
+		if country != "" {
+			add("countrySet=%s", url.QueryEscape(strings.ToUpper(country)))
+		}
+		if b != nil {
+			add("topLeft=%g,%g&btmRight=%g,%g", b.MaxLat, b.MinLng, b.MinLat, b.MaxLng)
+		}
+	case "bing":
+		// Bing has no hard country filter; userRegion only biases results.
+		if country != "" {
+			add("userRegion=%s", url.QueryEscape(strings.ToUpper(country)))
+		}
+		if b != nil {
+			add("userMapView=%g,%g,%g,%g", b.MinLat, b.MinLng, b.MaxLat, b.MaxLng)
+		}
+	case "geonames":
+		if country != "" {
+			add("country=%s", url.QueryEscape(strings.ToUpper(country)))
+		}
+		if b != nil {
+			add("south=%g&west=%g&north=%g&east=%g", b.MinLat, b.MinLng, b.MaxLat, b.MaxLng)
+		}
+	case "photon":
+		// Photon has no country filter.
+		if b != nil {
+			add("bbox=%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+		for _, layer := range photonLayers[c.opts.FeatureType] {
+			add("layer=%s", layer)
+		}
+	case "pelias":
+		if country != "" {
+			add("boundary.country=%s", url.QueryEscape(strings.ToUpper(country)))
+		}
+		if b != nil {
+			add("boundary.rect.min_lon=%g&boundary.rect.min_lat=%g&boundary.rect.max_lon=%g&boundary.rect.max_lat=%g",
+				b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+	case "yandex":
+		// Yandex has no country filter; the box is "lng,lat~lng,lat" and
+		// rspn=1 makes it a restriction rather than a hint.
+		if b != nil {
+			add("bbox=%g,%g~%g,%g&rspn=1", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "&" + strings.Join(params, "&")
+}
+
+// FeatureTypes lists the values Options.FeatureType accepts, as Nominatim
+// names them.
+var FeatureTypes = []string{"country", "state", "city", "settlement"}
+
+// photonLayers maps a Nominatim featuretype onto Photon's layer filter. A
+// settlement is any city, town or village.
+var photonLayers = map[string][]string{
+	"country":    {"country"},
+	"state":      {"state"},
+	"city":       {"city"},
+	"settlement": {"city", "locality"},
+}
+
+// langParams returns the query parameter (prefixed with '&') requesting
+// results in Options.Language for the given provider. Nominatim takes the
+// language as a header instead; see osmHeader.
+func (c *client) langParams(provider string) string {
+	lang := c.opts.Language
+	if lang == "" {
+		return ""
+	}
+	switch provider {
+	case "google", "opencage", "mapbox", "tomtom":
+		return "&language=" + url.QueryEscape(lang)
+	case "locationiq":
+		return "&accept-language=" + url.QueryEscape(lang)
+	case "pelias", "photon", "geonames", "here":
+		return "&lang=" + url.QueryEscape(lang)
+	case "bing":
+		return "&culture=" + url.QueryEscape(lang)
+	case "yandex":
+		// Yandex wants a language_REGION locale such as ru_RU and rejects
+		// bare language codes.
+		if strings.Contains(lang, "-") {
+			return "&lang=" + url.QueryEscape(strings.Replace(lang, "-", "_", 1))
+		}
+	}
+	return ""
+}
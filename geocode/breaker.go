@@ -0,0 +1,137 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for NewCircuitBreaker arguments left at zero.
+const (
+	DefaultBreakerWindow   = time.Minute
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// CircuitBreaker skips providers that keep failing, so a batch run does not
+// spend a timeout on a provider that is down for every row. After Threshold
+// consecutive failures within Window the provider's circuit opens and calls
+// to it fail with ErrCircuitOpen for Cooldown. The first call after that is
+// let through as a trial: success closes the circuit, failure reopens it.
+//
+// Only network, rate-limit and provider-side failures count; "no results"
+// and similar answers prove the provider is up, and lookups that our own
+// rate limits kept from being sent do not count either. It is safe for concurrent
+// use and is meant to be shared by every lookup in the process.
+type CircuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int       // consecutive failures in the current streak
+	first     time.Time // when the streak started
+	openUntil time.Time // zero while closed
+	trial     bool      // a half-open trial request is in flight
+}
+
+// NewCircuitBreaker returns a breaker that opens after threshold consecutive
+// failures within window and stays open for cooldown. Zero durations take
+// the defaults above; a threshold below one is treated as one.
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if window <= 0 {
+		window = DefaultBreakerWindow
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+// allow reports whether provider may be called now, returning ErrCircuitOpen
+// if not. Once the cooldown is over, only one caller at a time gets through.
+func (b *CircuitBreaker) allow(provider string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.states[provider]
+	if s == nil || s.openUntil.IsZero() {
+		return nil
+	}
+	if wait := time.Until(s.openUntil); wait > 0 {
+		return fmt.Errorf("%w, retrying in %s", ErrCircuitOpen, wait.Round(time.Second))
+	}
+	if s.trial {
+		return fmt.Errorf("%w, trial request in flight", ErrCircuitOpen)
+	}
+	s.trial = true
+	return nil
+}
+
+// record updates provider's circuit with the outcome of a call that allow
+// let through.
+func (b *CircuitBreaker) record(provider string, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.states[provider]
+	if s == nil {
+		s = &breakerState{}
+		b.states[provider] = s
+	}
+	trial := s.trial
+	s.trial = false
+	if errors.Is(err, context.Canceled) || errors.Is(err, errThrottled) {
+		// Abandoned by the caller (e.g. a lost race) or never sent because
+		// of our own rate limit: says nothing about the provider, and such
+		// a trial just lets the next one in.
+		return
+	}
+	if !breakerFailure(err) {
+		*s = breakerState{}
+		return
+	}
+	now := time.Now()
+	if trial {
+		s.openUntil = now.Add(b.cooldown)
+		return
+	}
+	if s.failures == 0 || now.Sub(s.first) > b.window {
+		s.failures, s.first = 0, now
+	}
+	s.failures++
+	if s.failures >= b.threshold {
+		s.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// breakerFailure reports whether err suggests the provider itself is in
+// trouble, as opposed to an answer or a local configuration or rate limit
+// problem.
+func breakerFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrMissingKey) || errors.Is(err, ErrUnsupported) || errors.Is(err, errThrottled) {
+		return false
+	}
+	switch ErrorClass(err) {
+	case ClassNetwork, ClassRateLimit, ClassProvider:
+		return true
+	}
+	return false
+}
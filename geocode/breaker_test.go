@@ -0,0 +1,83 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// A batch throttled by osmLimiter must not trip Nominatim's circuit: the
+// lookups that find no slot before their deadline never reach the server.
+func TestBreakerIgnoresLocalThrottling(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"lat":"52.5","lon":"13.4","display_name":"Berlin"}]`))
+	}))
+	t.Cleanup(srv.Close)
+	// Keep the Nominatim host, so requests go through osmLimiter, but
+	// connect to the stub.
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+
+	breaker := NewCircuitBreaker(2, time.Minute, time.Minute)
+	opts := Options{
+		Providers:   []string{"osm"},
+		Endpoints:   map[string]string{"osm": "http://" + osmHost},
+		HTTPClient:  &http.Client{Transport: transport},
+		Timeout:     200 * time.Millisecond,
+		Retries:     2,
+		OSMInterval: time.Hour,
+		FailFast:    true,
+		Breaker:     breaker,
+	}
+	t.Cleanup(func() { osmLimiter.SetLimit(rate.Every(DefaultOSMInterval)) })
+
+	const lookups = 6
+	errs := make([]error, lookups)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = Geocode(context.Background(), "Berlin", opts)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := hits.Load(); n > 1 {
+		t.Errorf("server got %d requests, want at most 1", n)
+	}
+	throttled := 0
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, errThrottled) {
+			t.Fatalf("Geocode error = %v, want errThrottled", err)
+		}
+		if class := ErrorClass(err); class != ClassNetwork {
+			t.Errorf("ErrorClass = %q, want %q", class, ClassNetwork)
+		}
+		throttled++
+	}
+	if throttled < lookups-1 {
+		t.Errorf("%d lookups throttled, want at least %d", throttled, lookups-1)
+	}
+	if err := breaker.allow("osm"); err != nil {
+		t.Errorf("breaker opened on local throttling: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package geocode
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores provider results between lookups. Keys are built from the
+// provider name and the normalized query, so a hit skips the HTTP call.
+type Cache interface {
+	Get(key string) ([]GeocodeResult, bool)
+	Put(key string, results []GeocodeResult)
+}
+
+// cacheKey builds the cache key for a lookup against provider p. Options
+// that change the provider's answer are part of the key.
+func (c *client) cacheKey(p Provider, req lookupRequest) string {
+	var key string
+	if req.reverse {
+		key = fmt.Sprintf("%s|reverse|%.6f,%.6f", p.Name, req.lat, req.lng)
+	} else {
+		// The address is already normalized, so this equals QueryKey.
+		key = fmt.Sprintf("%s|%d|%s", p.Name, c.opts.Limit, strings.ToLower(req.address))
+		if req.autocomplete {
+			key += "|autocomplete"
+		}
+		if req.autocomplete && c.opts.SessionToken != "" {
+			// Session suggestions have no coordinates.
+			key += "|session"
+		}
+		if req.structured != nil {
+			key += "|structured"
+		}
+	}
+	// Answers from a self-hosted or staging instance must not be served for
+	// the public one, or the other way around.
+	if base := c.endpointOverride(p.Name); base != "" {
+		key += "|endpoint=" + strings.TrimSuffix(base, "/")
+	}
+	if base := searchURLOverride(p.Name); base != "" {
+		key += "|url=" + base
+	}
+	if c.opts.Raw {
+		// Entries stored without the response body cannot serve --raw.
+		key += "|raw"
+	}
+	if c.opts.Language != "" {
+		key += "|lang=" + strings.ToLower(c.opts.Language)
+	}
+	if req.reverse {
+		return key
+	}
+	if c.opts.Country != "" {
+		key += "|country=" + strings.ToLower(c.opts.Country)
+	}
+	if c.opts.Region != "" {
+		key += "|region=" + strings.ToLower(c.opts.Region)
+	}
+	if c.opts.FeatureType != "" {
+		key += "|featuretype=" + c.opts.FeatureType
+	}
+	if b := c.opts.Bounds; b != nil {
+		key += fmt.Sprintf("|bounds=%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat)
+	}
+	return key
+}
+
+// FileCache is a Cache persisted as a single JSON file. Entries older than
+// the TTL are ignored on lookup and dropped on Save.
+type FileCache struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+type cacheEntry struct {
+	Stored  time.Time       `json:"stored"`
+	Results []GeocodeResult `json:"results"`
+}
+
+// OpenFileCache loads the cache at path, starting empty if the file does
+// not exist yet. A ttl of zero means entries never expire.
+func OpenFileCache(path string, ttl time.Duration) (*FileCache, error) {
+	c := &FileCache{path: path, ttl: ttl, entries: make(map[string]cacheEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("parse cache %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func (c *FileCache) expired(e cacheEntry) bool {
+	return c.ttl > 0 && time.Since(e.Stored) > c.ttl
+}
+
+// Get returns the cached results for key if present and not expired.
+func (c *FileCache) Get(key string) ([]GeocodeResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || c.expired(e) {
+		return nil, false
+	}
+	return e.Results, true
+}
+
+// Put stores results under key.
+func (c *FileCache) Put(key string, results []GeocodeResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{Stored: time.Now(), Results: results}
+	c.dirty = true
+}
+
+// Save writes the cache back to disk if it changed, pruning expired entries.
+func (c *FileCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	for k, e := range c.entries {
+		if c.expired(e) {
+			delete(c.entries, k)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0o644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// MemoryCache is a size-bounded in-process Cache that evicts the least
+// recently used entry when full. It is safe for concurrent use.
+type MemoryCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	results []GeocodeResult
+}
+
+// NewMemoryCache returns an LRU cache holding at most size entries.
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Get returns the cached results for key and marks it recently used.
+func (c *MemoryCache) Get(key string) ([]GeocodeResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoryEntry).results, true
+}
+
+// Put stores results under key, evicting the least recently used entry if
+// the cache is full.
+func (c *MemoryCache) Put(key string, results []GeocodeResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*memoryEntry).results = results
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key, results})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// tieredCache consults its caches in order, e.g. memory before disk.
+type tieredCache []Cache
+
+// TieredCache combines caches so lookups try each in order and a hit in a
+// later cache is copied into the earlier ones. Puts go to every cache.
+func TieredCache(caches ...Cache) Cache {
+	return tieredCache(caches)
+}
+
+func (t tieredCache) Get(key string) ([]GeocodeResult, bool) {
+	for i, c := range t {
+		if results, ok := c.Get(key); ok {
+			for _, earlier := range t[:i] {
+				earlier.Put(key, results)
+			}
+			return results, true
+		}
+	}
+	return nil, false
+}
+
+func (t tieredCache) Put(key string, results []GeocodeResult) {
+	for _, c := range t {
+		c.Put(key, results)
+	}
+}
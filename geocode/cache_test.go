@@ -0,0 +1,33 @@
+package geocode
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCacheKeyIncludesEndpoint(t *testing.T) {
+	cache := NewMemoryCache(10)
+	staging := stubProvider(t, "osm", `[{"lat":"1","lon":"2","display_name":"staging"}]`)
+	staging.Cache = cache
+	if _, err := Geocode(context.Background(), "Berlin", staging); err != nil {
+		t.Fatalf("Geocode against staging: %v", err)
+	}
+
+	prod := stubProvider(t, "osm", `[{"lat":"52.5","lon":"13.4","display_name":"prod"}]`)
+	prod.Cache = cache
+	res, err := Geocode(context.Background(), "Berlin", prod)
+	if err != nil {
+		t.Fatalf("Geocode against prod: %v", err)
+	}
+	if res.Formatted != "prod" {
+		t.Errorf("Formatted = %q, want %q: the staging answer was served from the cache", res.Formatted, "prod")
+	}
+
+	again, err := Geocode(context.Background(), "Berlin", staging)
+	if err != nil {
+		t.Fatalf("second Geocode against staging: %v", err)
+	}
+	if again.Formatted != "staging" {
+		t.Errorf("Formatted = %q, want %q", again.Formatted, "staging")
+	}
+}
@@ -0,0 +1,211 @@
+package geocode
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// ProviderAnswer is a single provider's contribution to a consensus lookup.
+type ProviderAnswer struct {
+	Provider string         `json:"provider"`
+	Result   *GeocodeResult `json:"result,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// ConsensusResult aggregates the answers of every provider into a median
+// coordinate. MaxDistance is the largest pairwise distance in meters between
+// successful answers and is a measure of how much the providers disagree.
+type ConsensusResult struct {
+	Address     string  `json:"address"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	MaxDistance float64 `json:"max_distance_m"`
+	// Contributors names the providers whose answers went into the median,
+	// so a quorum check can tell agreement from a lone survivor.
+	Contributors []string `json:"contributors"`
+	// Weights holds the trust weight applied to each contributor when the
+	// median was weighted; see WeightedConsensus.
+	Weights   map[string]float64 `json:"weights,omitempty"`
+	Providers []ProviderAnswer   `json:"providers"`
+	// Clusters groups the successful answers that agree with each other;
+	// see ClusterAnswers.
+	Clusters []Cluster `json:"clusters,omitempty"`
+}
+
+// Cluster is a group of providers whose answers lie close together.
+// Latitude and Longitude are the centroid of the group.
+type Cluster struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Providers []string `json:"providers"`
+}
+
+// QueryAll geocodes address against every provider concurrently and returns
+// one answer per provider, in provider order. Like GeocodeMany, it queries
+// the normalized address.
+func QueryAll(ctx context.Context, address string, opts Options) ([]ProviderAnswer, error) {
+	c := newClient(opts)
+	providers, err := c.providers()
+	if err != nil {
+		return nil, err
+	}
+	req := lookupRequest{address: NormalizeAddress(address, c.opts.ExpandAbbreviations)}
+	ctx, cancel := c.total(ctx)
+	defer cancel()
+
+	answers := make([]ProviderAnswer, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p Provider) {
+			defer wg.Done()
+			actx, cancel := c.attempt(ctx)
+			defer cancel()
+			answers[i].Provider = p.Name
+			results, err := c.lookup(actx, p, req)
+			if err != nil {
+				answers[i].Error = err.Error()
+				return
+			}
+			answers[i].Result = &results[0]
+		}(i, p)
+	}
+	wg.Wait()
+	return answers, nil
+}
+
+// Consensus computes the median coordinate of all successful answers. It
+// reports false when no provider succeeded. Answers are listed by descending
+// confidence, with failed providers last.
+func Consensus(address string, answers []ProviderAnswer) (ConsensusResult, bool) {
+	return WeightedConsensus(address, answers, nil)
+}
+
+// WeightedConsensus is like Consensus but takes the weighted median, so a
+// provider with weight 2 counts as much as two with weight 1. Providers
+// missing from weights count with weight 1; a nil map gives the plain
+// median.
+func WeightedConsensus(address string, answers []ProviderAnswer, weights map[string]float64) (ConsensusResult, bool) {
+	answers = append([]ProviderAnswer(nil), answers...)
+	sort.SliceStable(answers, func(i, j int) bool {
+		return answerConfidence(answers[i]) > answerConfidence(answers[j])
+	})
+	res := ConsensusResult{Address: address, Providers: answers}
+	var lats, lngs, ws []float64
+	var ok []GeocodeResult
+	for _, a := range answers {
+		if a.Result == nil {
+			continue
+		}
+		w := 1.0
+		if weights != nil {
+			if v, found := weights[a.Provider]; found {
+				w = v
+			}
+			if res.Weights == nil {
+				res.Weights = make(map[string]float64)
+			}
+			res.Weights[a.Provider] = w
+		}
+		lats = append(lats, a.Result.Latitude)
+		lngs = append(lngs, a.Result.Longitude)
+		ws = append(ws, w)
+		ok = append(ok, *a.Result)
+		res.Contributors = append(res.Contributors, a.Provider)
+	}
+	if len(ok) == 0 {
+		return res, false
+	}
+	res.Latitude = weightedMedian(lats, ws)
+	res.Longitude = weightedMedian(lngs, ws)
+	for i := range ok {
+		for j := i + 1; j < len(ok); j++ {
+			d := Haversine(ok[i].Latitude, ok[i].Longitude, ok[j].Latitude, ok[j].Longitude)
+			if d > res.MaxDistance {
+				res.MaxDistance = d
+			}
+		}
+	}
+	return res, true
+}
+
+// ClusterAnswers groups successful answers whose coordinates lie within
+// maxDistance meters of a cluster's centroid. Answers are taken in order, so
+// higher-confidence answers seed clusters when the input comes from
+// Consensus. Clusters are returned largest first; a lone provider in its own
+// cluster is an outlier.
+func ClusterAnswers(answers []ProviderAnswer, maxDistance float64) []Cluster {
+	var clusters []Cluster
+	for _, a := range answers {
+		if a.Result == nil {
+			continue
+		}
+		lat, lng := a.Result.Latitude, a.Result.Longitude
+		joined := false
+		for i := range clusters {
+			cl := &clusters[i]
+			if Haversine(cl.Latitude, cl.Longitude, lat, lng) > maxDistance {
+				continue
+			}
+			n := float64(len(cl.Providers))
+			cl.Latitude = (cl.Latitude*n + lat) / (n + 1)
+			cl.Longitude = (cl.Longitude*n + lng) / (n + 1)
+			cl.Providers = append(cl.Providers, a.Provider)
+			joined = true
+			break
+		}
+		if !joined {
+			clusters = append(clusters, Cluster{Latitude: lat, Longitude: lng, Providers: []string{a.Provider}})
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Providers) > len(clusters[j].Providers)
+	})
+	return clusters
+}
+
+// answerConfidence ranks failed answers below every successful one.
+func answerConfidence(a ProviderAnswer) float64 {
+	if a.Result == nil {
+		return -1
+	}
+	return a.Result.Confidence
+}
+
+// Haversine returns the great-circle distance in meters between two points.
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371008.8 // mean radius in meters
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// weightedMedian returns the value at which the cumulative weight of the
+// sorted values reaches half the total. When it lands exactly on half, the
+// two neighbouring values are averaged, so equal weights give the median.
+// values must not be empty and weights must be positive.
+func weightedMedian(values, weights []float64) float64 {
+	idx := make([]int, len(values))
+	total := 0.0
+	for i := range idx {
+		idx[i] = i
+		total += weights[i]
+	}
+	sort.Slice(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+	cum := 0.0
+	for k, i := range idx {
+		cum += weights[i]
+		if cum > total/2 {
+			return values[i]
+		}
+		if cum == total/2 && k+1 < len(idx) {
+			return (values[i] + values[idx[k+1]]) / 2
+		}
+	}
+	return values[idx[len(idx)-1]]
+}
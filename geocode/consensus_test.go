@@ -0,0 +1,31 @@
+package geocode
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want, tolerance        float64 // meters
+	}{
+		{"same point", 52.52, 13.405, 52.52, 13.405, 0, 0},
+		// Half the circumference of the mean-radius sphere.
+		{"antipodal on the equator", 0, 0, 0, 180, math.Pi * 6371008.8, 1},
+		{"antipodal through the poles", 90, 0, -90, 0, math.Pi * 6371008.8, 1},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343_557, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Haversine(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("Haversine = %.1f m, want %.1f ± %.0f m", got, tt.want, tt.tolerance)
+			}
+			if back := Haversine(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(back-got) > 1e-6 {
+				t.Errorf("not symmetric: %.3f m one way, %.3f m back", got, back)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package geocode
+
+import (
+	"fmt"
+	"math"
+)
+
+// FormatDMS renders a decimal-degree coordinate as degrees, minutes and
+// seconds with a hemisphere letter, e.g. 40°26'46.3"N. isLat selects N/S
+// rather than E/W. Seconds are rounded to one decimal place.
+func FormatDMS(deg float64, isLat bool) string {
+	hemi := "E"
+	if isLat {
+		hemi = "N"
+	}
+	if deg < 0 {
+		hemi = "W"
+		if isLat {
+			hemi = "S"
+		}
+	}
+
+	// Work in tenths of a second so rounding carries into minutes and
+	// degrees instead of printing 60.0".
+	tenths := int64(math.Round(math.Abs(deg) * 36000))
+	d := tenths / 36000
+	m := tenths % 36000 / 600
+	s := float64(tenths%600) / 10
+	return fmt.Sprintf("%d°%02d'%04.1f\"%s", d, m, s, hemi)
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of a coordinate with the given number of
+// characters (1-12).
+func EncodeGeohash(lat, lng float64, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+	latMin, latMax := -90.0, 90.0
+	lngMin, lngMax := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true // even bits encode longitude
+	for len(hash) < precision {
+		if even {
+			mid := (lngMin + lngMax) / 2
+			if lng >= mid {
+				ch |= 1 << (4 - bit)
+				lngMin = mid
+			} else {
+				lngMax = mid
+			}
+		} else {
+			mid := (latMin + latMax) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				latMin = mid
+			} else {
+				latMax = mid
+			}
+		}
+		even = !even
+		if bit < 4 {
+			bit++
+			continue
+		}
+		hash = append(hash, geohashAlphabet[ch])
+		bit, ch = 0, 0
+	}
+	return string(hash)
+}
+
+const (
+	olcAlphabet = "23456789CFGHJMPQRVWX"
+	// Coordinates are scaled to integers at the precision of the finest
+	// grid refinement (5 rows x 4 columns, 5 levels) below the pair codes,
+	// which avoids floating point drift between digits.
+	olcPairPrecision = 8000
+	olcGridRows      = 3125 // 5^5
+	olcGridCols      = 1024 // 4^5
+	olcFinalLat      = olcPairPrecision * olcGridRows
+	olcFinalLng      = olcPairPrecision * olcGridCols
+)
+
+// EncodePlusCode returns the full 10-digit Open Location Code (plus code)
+// for a coordinate, e.g. 8FVC9G8F+6X. Its area is roughly 14 x 14 meters.
+func EncodePlusCode(lat, lng float64) string {
+	latVal := int64(math.Floor(math.Round((lat+90)*olcFinalLat*1e6) / 1e6))
+	lngVal := int64(math.Floor(math.Round((lng+180)*olcFinalLng*1e6) / 1e6))
+
+	// Clip latitude to [-90, 90) and wrap longitude into [-180, 180).
+	if latVal < 0 {
+		latVal = 0
+	}
+	if latVal >= 180*olcFinalLat {
+		latVal = 180*olcFinalLat - 1
+	}
+	lngVal %= 360 * olcFinalLng
+	if lngVal < 0 {
+		lngVal += 360 * olcFinalLng
+	}
+
+	latVal /= olcGridRows
+	lngVal /= olcGridCols
+
+	// Five digit pairs, built from the least significant end.
+	code := make([]byte, 10)
+	for i := 4; i >= 0; i-- {
+		code[2*i] = olcAlphabet[latVal%20]
+		code[2*i+1] = olcAlphabet[lngVal%20]
+		latVal /= 20
+		lngVal /= 20
+	}
+	return string(code[:8]) + "+" + string(code[8:])
+}
+
+// UTM is a coordinate in the Universal Transverse Mercator system on the
+// WGS84 ellipsoid. Easting and Northing are in meters; Northing is offset
+// by 10,000 km in the southern hemisphere as usual.
+type UTM struct {
+	Zone       int     `json:"zone"`
+	Hemisphere string  `json:"hemisphere"` // "N" or "S"
+	Easting    float64 `json:"easting"`
+	Northing   float64 `json:"northing"`
+}
+
+// String formats u as zone, hemisphere, easting and northing, e.g.
+// "18N 583959 4507351".
+func (u UTM) String() string {
+	return fmt.Sprintf("%d%s %.0f %.0f", u.Zone, u.Hemisphere, u.Easting, u.Northing)
+}
+
+// utmZone returns the UTM zone number for a coordinate, including the
+// irregular zones around Norway and Svalbard.
+func utmZone(lat, lng float64) int {
+	if lat >= 56 && lat < 64 && lng >= 3 && lng < 12 {
+		return 32
+	}
+	if lat >= 72 {
+		switch {
+		case lng >= 0 && lng < 9:
+			return 31
+		case lng >= 9 && lng < 21:
+			return 33
+		case lng >= 21 && lng < 33:
+			return 35
+		case lng >= 33 && lng < 42:
+			return 37
+		}
+	}
+	zone := int(math.Floor((lng+180)/6)) + 1
+	if zone > 60 {
+		zone = 60 // lng == 180
+	}
+	return zone
+}
+
+// ToUTM converts a WGS84 coordinate to UTM using the series expansion from
+// Snyder, "Map Projections: A Working Manual" (USGS 1987), which is accurate
+// to well under a meter within a zone. It reports false outside UTM's
+// latitude range of 80°S to 84°N.
+func ToUTM(lat, lng float64) (UTM, bool) {
+	if lat < -80 || lat > 84 {
+		return UTM{}, false
+	}
+	const (
+		k0 = 0.9996
+		a  = 6378137.0         // WGS84 semi-major axis
+		f  = 1 / 298.257223563 // WGS84 flattening
+	)
+	e2 := f * (2 - f)
+	e4 := e2 * e2
+	e6 := e4 * e2
+	ep2 := e2 / (1 - e2)
+
+	zone := utmZone(lat, lng)
+	lng0 := float64(zone-1)*6 - 180 + 3
+	phi := lat * math.Pi / 180
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+
+	n := a / math.Sqrt(1-e2*sin*sin)
+	t := tan * tan
+	c := ep2 * cos * cos
+	A := cos * (lng - lng0) * math.Pi / 180
+	m := a * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+
+	u := UTM{Zone: zone, Hemisphere: "N"}
+	u.Easting = k0*n*(A+(1-t+c)*math.Pow(A, 3)/6+
+		(5-18*t+t*t+72*c-58*ep2)*math.Pow(A, 5)/120) + 500000
+	u.Northing = k0 * (m + n*tan*(A*A/2+(5-t+9*c+4*c*c)*math.Pow(A, 4)/24+
+		(61-58*t+t*t+600*c-330*ep2)*math.Pow(A, 6)/720))
+	if lat < 0 {
+		u.Hemisphere = "S"
+		u.Northing += 10000000
+	}
+	return u, true
+}
@@ -0,0 +1,116 @@
+package geocode
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFormatDMS(t *testing.T) {
+	tests := []struct {
+		deg   float64
+		isLat bool
+		want  string
+	}{
+		{40.446195, true, `40°26'46.3"N`},
+		{-33.8688, true, `33°52'07.7"S`},
+		{151.2093, false, `151°12'33.5"E`},
+		{-73.98, false, `73°58'48.0"W`},
+		{-0.5, true, `0°30'00.0"S`},
+		{0, false, `0°00'00.0"E`},
+		// 59.96" rounds to 60.0" and carries into the minutes and degrees.
+		{10.99999, true, `11°00'00.0"N`},
+		{-179.999999, false, `180°00'00.0"W`},
+	}
+	for _, tt := range tests {
+		if got := FormatDMS(tt.deg, tt.isLat); got != tt.want {
+			t.Errorf("FormatDMS(%v, %v) = %s, want %s", tt.deg, tt.isLat, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeGeohash(t *testing.T) {
+	tests := []struct {
+		lat, lng  float64
+		precision int
+		want      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{-25.382708, -49.265506, 9, "6gkzwgjzn"},
+		{42.6, -5.6, 5, "ezs42"},
+		// Precision is clamped to 1-12 characters.
+		{57.64911, 10.40744, 0, "u"},
+		{57.64911, 10.40744, -3, "u"},
+	}
+	for _, tt := range tests {
+		if got := EncodeGeohash(tt.lat, tt.lng, tt.precision); got != tt.want {
+			t.Errorf("EncodeGeohash(%v, %v, %d) = %q, want %q", tt.lat, tt.lng, tt.precision, got, tt.want)
+		}
+	}
+	if got := EncodeGeohash(57.64911, 10.40744, 20); len(got) != 12 || !strings.HasPrefix(got, "u4pruydqqvj") {
+		t.Errorf("EncodeGeohash at precision 20 = %q, want 12 characters starting u4pruydqqvj", got)
+	}
+}
+
+// Vectors from the Open Location Code test data (encoding.csv), at the
+// default ten-digit length.
+func TestEncodePlusCode(t *testing.T) {
+	tests := []struct {
+		lat, lng float64
+		want     string
+	}{
+		{47.365590, 8.524997, "8FVC9G8F+6X"},
+		{20.3701125, 2.782234375, "7FG49QCJ+2V"},
+		{47.0000625, 8.0000625, "8FVC2222+22"},
+		{-41.2730625, 174.7859375, "4VCPPQGP+Q9"},
+		{90, 1, "CFX3X2X2+X2"},
+	}
+	for _, tt := range tests {
+		if got := EncodePlusCode(tt.lat, tt.lng); got != tt.want {
+			t.Errorf("EncodePlusCode(%v, %v) = %q, want %q", tt.lat, tt.lng, got, tt.want)
+		}
+	}
+}
+
+func TestToUTM(t *testing.T) {
+	tests := []struct {
+		name              string
+		lat, lng          float64
+		zone              int
+		hemisphere        string
+		easting, northing float64
+	}{
+		{"Sydney", -33.8688, 151.2093, 56, "S", 334369, 6250948},
+		// South of the equator northings count down from the 10,000 km
+		// false northing.
+		{"just south of the equator", -0.000001, 3, 31, "S", 500000, 10000000},
+		{"equator on a central meridian", 0, 3, 31, "N", 500000, 0},
+		// Southwest Norway belongs to the widened zone 32.
+		{"Bergen", 60.39, 5.32, 32, "N", 297230, 6700510},
+		// Svalbard uses the odd zones 31, 33, 35 and 37 only.
+		{"Longyearbyen", 78.22, 15.65, 33, "N", 514814, 8683004},
+		{"eastern Svalbard", 78.22, 25, 35, "N", 454428, 8683701},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, ok := ToUTM(tt.lat, tt.lng)
+			if !ok {
+				t.Fatalf("ToUTM(%v, %v) not ok", tt.lat, tt.lng)
+			}
+			if u.Zone != tt.zone || u.Hemisphere != tt.hemisphere {
+				t.Errorf("zone = %d%s, want %d%s", u.Zone, u.Hemisphere, tt.zone, tt.hemisphere)
+			}
+			if math.Abs(u.Easting-tt.easting) > 1 || math.Abs(u.Northing-tt.northing) > 1 {
+				t.Errorf("position = %.1f %.1f, want %.0f %.0f", u.Easting, u.Northing, tt.easting, tt.northing)
+			}
+		})
+	}
+}
+
+func TestToUTMOutOfRange(t *testing.T) {
+	for _, lat := range []float64{-80.5, -90, 84.5, 90} {
+		if u, ok := ToUTM(lat, 0); ok {
+			t.Errorf("ToUTM(%v, 0) = %v, want not ok", lat, u)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type GoogleElevationResponse struct {
+	Results []struct {
+		Elevation float64 `json:"elevation"`
+	} `json:"results"`
+	Status string `json:"status"`
+}
+
+type OpenElevationResponse struct {
+	Results []struct {
+		Elevation float64 `json:"elevation"`
+	} `json:"results"`
+}
+
+// Elevation returns the ground elevation in meters at a coordinate. It uses
+// the Google Elevation API when a Google key is available and falls back to
+// the keyless Open-Elevation service.
+func Elevation(ctx context.Context, lat, lng float64, opts Options) (float64, error) {
+	c := newClient(opts)
+	var errs []error
+	for _, lookup := range []func(context.Context, float64, float64) (float64, error){
+		c.elevationGoogle,
+		c.elevationOpen,
+	} {
+		actx, cancel := c.attempt(ctx)
+		elevation, err := lookup(actx, lat, lng)
+		cancel()
+		if err == nil {
+			return elevation, nil
+		}
+		errs = append(errs, err)
+	}
+	return 0, fmt.Errorf("elevation: %w", errors.Join(errs...))
+}
+
+func (c *client) elevationGoogle(ctx context.Context, lat, lng float64) (float64, error) {
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return 0, missingKey("GOOGLE_API_KEY")
+	}
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/elevation/json")
+	query := fmt.Sprintf("%s?locations=%f,%f&key=%s", endpoint, lat, lng, apiKey)
+	var result GoogleElevationResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return 0, err
+	}
+	if result.Status != "OK" {
+		return 0, googleStatusError(result.Status)
+	}
+	if len(result.Results) == 0 {
+		return 0, ErrNoResults
+	}
+	return result.Results[0].Elevation, nil
+}
+
+func (c *client) elevationOpen(ctx context.Context, lat, lng float64) (float64, error) {
+	endpoint := "https://api.open-elevation.com/api/v1/lookup"
+	query := fmt.Sprintf("%s?locations=%f,%f", endpoint, lat, lng)
+	var result OpenElevationResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return 0, err
+	}
+	if len(result.Results) == 0 {
+		return 0, ErrNoResults
+	}
+	return result.Results[0].Elevation, nil
+}
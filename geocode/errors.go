@@ -0,0 +1,199 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Error classes returned (wrapped) by provider lookups. Use errors.Is to
+// test for them.
+var (
+	// ErrAllFailed is returned, as an *AllFailedError, when no provider
+	// produced a result.
+	ErrAllFailed = errors.New("all providers failed")
+	// ErrNoResults means the provider answered but found no match.
+	ErrNoResults = errors.New("no results")
+	// ErrMissingKey means the provider needs an API key and none was set.
+	ErrMissingKey = errors.New("API key not set")
+	// ErrAuth means the provider rejected the API key (HTTP 401/403 or an
+	// equivalent status in the response body).
+	ErrAuth = errors.New("authentication failed")
+	// ErrRateLimited means the provider throttled the request and retries
+	// were exhausted.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrProvider is any other provider-side failure, such as a 5xx
+	// response or an error status in the response body.
+	ErrProvider = errors.New("provider error")
+	// ErrLowConfidence means the provider's best match scored below
+	// Options.MinConfidence.
+	ErrLowConfidence = errors.New("match confidence too low")
+	// ErrBadRequest means the provider rejected the query itself as
+	// malformed, as opposed to finding no match for it.
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnsupported means the provider does not support the operation,
+	// e.g. reverse geocoding.
+	ErrUnsupported = errors.New("not supported")
+	// ErrCircuitOpen means the provider was skipped without a request
+	// because its circuit breaker is open; see CircuitBreaker.
+	ErrCircuitOpen = errors.New("circuit open, skipping")
+)
+
+// Failure classes accepted by Options.FallbackOn; see ErrorClass.
+const (
+	ClassNetwork       = "network" // connection failures and timeouts
+	ClassRateLimit     = "ratelimit"
+	ClassAuth          = "auth"
+	ClassProvider      = "provider" // 5xx and other provider-side errors
+	ClassNoResults     = "noresults"
+	ClassLowConfidence = "lowconfidence"
+	ClassBadRequest    = "badrequest"
+)
+
+// FailureClasses lists every class ErrorClass can return.
+var FailureClasses = []string{
+	ClassNetwork, ClassRateLimit, ClassAuth, ClassProvider,
+	ClassNoResults, ClassLowConfidence, ClassBadRequest,
+}
+
+// ErrorClass names the failure class of a provider error. Errors that match
+// no sentinel and are not network errors count as ClassProvider.
+func ErrorClass(err error) string {
+	var nerr net.Error
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return ClassRateLimit
+	case errors.Is(err, ErrAuth):
+		return ClassAuth
+	case errors.Is(err, ErrNoResults):
+		return ClassNoResults
+	case errors.Is(err, ErrLowConfidence):
+		return ClassLowConfidence
+	case errors.Is(err, ErrBadRequest):
+		return ClassBadRequest
+	case errors.Is(err, ErrProvider):
+		return ClassProvider
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &nerr):
+		return ClassNetwork
+	}
+	return ClassProvider
+}
+
+// Attempt records why one provider failed during a lookup.
+type Attempt struct {
+	Provider string `json:"provider"`
+	// Class is the failure class; see ErrorClass.
+	Class string `json:"class"`
+	Error string `json:"error"`
+}
+
+// AllFailedError is returned when no provider produced a result. It matches
+// ErrAllFailed with errors.Is and lists each provider's failure in the order
+// they were tried (or, with Options.Race, finished).
+type AllFailedError struct {
+	Attempts []Attempt
+}
+
+func (e *AllFailedError) Error() string { return ErrAllFailed.Error() }
+
+func (e *AllFailedError) Unwrap() error { return ErrAllFailed }
+
+// add records that provider failed with err.
+func (e *AllFailedError) add(provider string, err error) {
+	e.Attempts = append(e.Attempts, Attempt{Provider: provider, Class: ErrorClass(err), Error: err.Error()})
+}
+
+// missingKey reports that the environment variable env holds no key.
+func missingKey(env string) error {
+	return fmt.Errorf("%w: %s not set", ErrMissingKey, env)
+}
+
+// googleStatusError maps a non-OK Google status to an error class.
+func googleStatusError(status string) error {
+	switch status {
+	case "ZERO_RESULTS":
+		return ErrNoResults
+	case "REQUEST_DENIED":
+		return fmt.Errorf("%w (status: %s)", ErrAuth, status)
+	case "OVER_QUERY_LIMIT", "OVER_DAILY_LIMIT":
+		return fmt.Errorf("%w (status: %s)", ErrRateLimited, status)
+	default:
+		return fmt.Errorf("%w (status: %s)", ErrProvider, status)
+	}
+}
+
+// mapQuestStatusError maps a nonzero MapQuest info.statuscode to an error
+// class, including MapQuest's own explanation from info.messages.
+func mapQuestStatusError(code int, messages []string) error {
+	class := ErrProvider // 500 and anything undocumented
+	switch code {
+	case 400:
+		class = ErrBadRequest
+	case 403:
+		class = ErrAuth
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("%w (statuscode: %d)", class, code)
+	}
+	return fmt.Errorf("%w (statuscode: %d): %s", class, code, strings.Join(messages, "; "))
+}
+
+// geoNamesStatusError maps a GeoNames error status to an error class.
+// GeoNames reports errors in the body with HTTP 200.
+func geoNamesStatusError(value int, message string) error {
+	class := ErrProvider
+	switch value {
+	case 10: // user account not enabled or invalid
+		class = ErrAuth
+	case 18, 19, 20: // daily, hourly or weekly limit exceeded
+		class = ErrRateLimited
+	case 15: // no result found
+		class = ErrNoResults
+	}
+	return fmt.Errorf("%w (status: %d): %s", class, value, message)
+}
+
+// maxErrorBody limits how much of a response body an HTTPError carries.
+const maxErrorBody = 200
+
+// HTTPError is returned for a non-2xx provider response. It wraps the
+// matching error class (ErrAuth, ErrRateLimited, ErrBadRequest or
+// ErrProvider), so errors.Is works as for any other provider error.
+type HTTPError struct {
+	StatusCode int
+	// Body is the start of the response body, which often explains the
+	// failure (or is an HTML error page).
+	Body  string
+	class error
+}
+
+func newHTTPError(status int, body []byte) *HTTPError {
+	var class error
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		class = ErrAuth
+	case status == http.StatusTooManyRequests:
+		class = ErrRateLimited
+	case status >= 400 && status < 500:
+		class = ErrBadRequest
+	default:
+		class = ErrProvider
+	}
+	b := strings.Join(strings.Fields(string(body)), " ")
+	if len(b) > maxErrorBody {
+		b = b[:maxErrorBody] + "..."
+	}
+	return &HTTPError{StatusCode: status, Body: b, class: class}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%v (HTTP %d)", e.class, e.StatusCode)
+	}
+	return fmt.Sprintf("%v (HTTP %d): %s", e.class, e.StatusCode, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error { return e.class }
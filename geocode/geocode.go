@@ -0,0 +1,722 @@
+// Package geocode resolves addresses to coordinates (and back) using a chain
+// of public geocoding providers, falling back to the next provider when one
+// fails.
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------- Output struct -----------
+
+type GeocodeResult struct {
+	Provider  string  `json:"provider"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// LatitudeDMS and LongitudeDMS are set when callers request
+	// degrees-minutes-seconds output; see FormatDMS.
+	LatitudeDMS  string `json:"latitude_dms,omitempty"`
+	LongitudeDMS string `json:"longitude_dms,omitempty"`
+	// UTM is set when callers request UTM output; see ToUTM.
+	UTM *UTM `json:"utm,omitempty"`
+	// Geohash is set when callers request it; see EncodeGeohash.
+	Geohash string `json:"geohash,omitempty"`
+	// PlusCode is set when callers request it; see EncodePlusCode.
+	PlusCode string `json:"plus_code,omitempty"`
+	// Elevation is the ground elevation in meters, set when callers request
+	// it; see Elevation. It is a pointer so that sea level (0 m) is kept.
+	Elevation *float64 `json:"elevation,omitempty"`
+	// TimeZoneID and UTCOffset (e.g. "+02:00") describe the local time zone,
+	// set when callers request it; see TimeZone.
+	TimeZoneID string `json:"timezone_id,omitempty"`
+	UTCOffset  string `json:"utc_offset,omitempty"`
+	// Words is the what3words address, set when callers request it; see
+	// What3Words.
+	Words string `json:"words,omitempty"`
+	// Confidence is the provider's match quality normalized to 0-1, or zero
+	// when the provider does not report one.
+	Confidence float64 `json:"confidence,omitempty"`
+	// MatchType is the granularity of the match: one of the Match*
+	// constants, or empty when the provider does not report it.
+	MatchType string `json:"match_type,omitempty"`
+	// Formatted is the provider's canonical form of the matched address.
+	Formatted string `json:"formatted,omitempty"`
+	// Components holds structured address parts (city, postcode, country,
+	// ...) under the provider's own key names.
+	Components map[string]string `json:"components,omitempty"`
+	// PlaceID is Google's identifier for the place, set on Google
+	// autocomplete suggestions; see GeocodePlace.
+	PlaceID string `json:"place_id,omitempty"`
+	// Unresolved marks a suggestion that has no coordinates yet, i.e. a
+	// Google suggestion under Options.SessionToken; resolve it with
+	// GeocodePlace. Its Latitude and Longitude are zero and left out of
+	// JSON.
+	Unresolved bool `json:"unresolved,omitempty"`
+	// Attribution is the notice the provider's terms require when showing
+	// the result, taken from the response when the provider sends one.
+	Attribution string `json:"attribution,omitempty"`
+	// Raw is the provider's response body, with echoed credentials
+	// redacted, when Options.Raw is set. It covers the whole response the
+	// result was parsed from, not just this match.
+	Raw   json.RawMessage `json:"raw,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// MarshalJSON omits the coordinates of unresolved suggestions, which would
+// otherwise read as a real 0,0 match.
+func (r GeocodeResult) MarshalJSON() ([]byte, error) {
+	type plain GeocodeResult
+	if !r.Unresolved {
+		return json.Marshal(plain(r))
+	}
+	// The outer fields shadow the embedded ones and, being nil, are omitted.
+	return json.Marshal(struct {
+		plain
+		Latitude  *float64 `json:"latitude,omitempty"`
+		Longitude *float64 `json:"longitude,omitempty"`
+	}{plain: plain(r)})
+}
+
+// setComponent records a non-empty address component.
+func (r *GeocodeResult) setComponent(key, value string) {
+	if value == "" {
+		return
+	}
+	if r.Components == nil {
+		r.Components = make(map[string]string)
+	}
+	r.Components[key] = value
+}
+
+// setComponents copies the string and numeric values of a loosely typed
+// address object into r.Components.
+func setComponents(r *GeocodeResult, m map[string]any) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			r.setComponent(k, v)
+		case float64:
+			r.setComponent(k, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+}
+
+// ----------- Options -----------
+
+// DefaultUserAgent identifies geolooker to providers when Options.UserAgent
+// is empty.
+const DefaultUserAgent = "geolooker/1.0 (+https://github.com/fasoulas/geolooker)"
+
+// Options controls how a lookup is performed. The zero value tries every
+// provider in the default order with keys taken from the environment.
+type Options struct {
+	// Providers lists provider names in the order they are tried. When
+	// empty, all providers are tried in the default order.
+	Providers []string
+	// Keys maps a provider name to its API key. Providers without an entry
+	// fall back to their environment variable.
+	Keys map[string]string
+	// Endpoints maps a provider name to the base URL of its API, replacing
+	// the public one, e.g. {"osm": "https://nominatim.internal"}. Providers
+	// without an entry fall back to their <NAME>_ENDPOINT environment
+	// variable. Pelias and Photon take full search URLs in PELIAS_URL and
+	// PHOTON_URL instead.
+	Endpoints map[string]string
+	// RequireHTTPS refuses to send requests over plain HTTP, e.g. to an
+	// http:// endpoint override, so keys and addresses never travel in
+	// cleartext. A provider refused this way fails with ErrUnsupported and
+	// is skipped; see PlainHTTPEndpoint. Every default endpoint uses HTTPS
+	// except positionstack's, whose free plan has no HTTPS: this option
+	// switches it to HTTPS, which needs a paid plan.
+	RequireHTTPS bool
+	// HTTPClient is used for all provider requests. Defaults to a shared
+	// client with connection reuse; see NewHTTPClient.
+	HTTPClient *http.Client
+	// UserAgent is sent with every provider request. Nominatim's usage
+	// policy asks for one that identifies the application and a contact.
+	// Defaults to DefaultUserAgent.
+	UserAgent string
+	// Limit is the maximum number of results returned by GeocodeMany.
+	// Defaults to 1.
+	Limit int
+	// Country restricts forward lookups to an ISO 3166-1 alpha-2 country
+	// code on providers that support it.
+	Country string
+	// Region is a soft ccTLD bias ("uk", "de", ...) that changes how
+	// ambiguous queries are resolved without excluding other countries. Only
+	// Google consumes it; see biasParams.
+	Region string
+	// FeatureType restricts forward lookups to one of FeatureTypes on
+	// providers that support it (Nominatim and Photon), e.g. "state" to get
+	// the state of Washington rather than the city. Others ignore it.
+	FeatureType string
+	// Bounds biases or restricts forward lookups to a bounding box on
+	// providers that support it.
+	Bounds *Bounds
+	// Language requests localized names and formatted addresses, as a
+	// BCP 47 tag such as "de" or "pt-BR". Empty uses each provider's default.
+	Language string
+	// Timeout bounds each provider attempt. Zero means only ctx applies.
+	Timeout time.Duration
+	// TotalTimeout bounds a whole lookup across all providers, retries and
+	// fallbacks. Each attempt's Timeout is capped by what remains of it. Zero
+	// means only ctx applies.
+	TotalTimeout time.Duration
+	// Retries is how many times a request is retried after a rate-limit,
+	// server or network error. Retries never outlast Timeout.
+	Retries int
+	// MaxRetryAfter caps how long a retry waits when a rate-limited
+	// provider sends a Retry-After header. Zero means no cap; either way a
+	// wait that would outlast Timeout ends the retries instead.
+	MaxRetryAfter time.Duration
+	// Rand, if set, is the source of retry jitter, so backoff delays are
+	// reproducible under test. Calls to it are serialized, so it may be
+	// shared by concurrent lookups.
+	Rand *rand.Rand
+	// OSMInterval is the minimum time between requests to Nominatim,
+	// enforced across all goroutines. Defaults to DefaultOSMInterval.
+	OSMInterval time.Duration
+	// QPS caps outbound requests per second across all providers and
+	// goroutines. Zero means no global limit; OSMInterval still applies.
+	QPS float64
+	// Race queries all providers concurrently and keeps the first success
+	// instead of trying them one after another. The winner depends on
+	// timing, so unlike the sequential order it is not reproducible.
+	Race bool
+	// MinConfidence rejects matches scoring below it, so a provider whose
+	// best match is weaker counts as failed. Providers that report no
+	// confidence are never rejected. Zero disables the check.
+	MinConfidence float64
+	// Require maps address components to values every forward result must
+	// have, e.g. {"country": "US", "postcode": "90210"}, compared without
+	// case. "country", "postcode", "city" and "state" also match each
+	// provider's own key for them; other names must match a Components key
+	// exactly. A provider with no matching result counts as failed.
+	Require map[string]string
+	// ExpandAbbreviations spells out a street-type abbreviation (St,
+	// Ave, ...) before querying; see NormalizeAddress. Whitespace is always
+	// normalized.
+	ExpandAbbreviations bool
+	// RejectNullIsland treats an exact 0,0 coordinate as invalid. It is a
+	// real point in the Gulf of Guinea, but far more often the product of a
+	// provider parse failure.
+	RejectNullIsland bool
+	// FailFast stops at the first provider that fails and returns its error
+	// instead of falling back to the next one. It has no effect with Race.
+	FailFast bool
+	// FallbackOn, if non-empty, lists the failure classes (see ErrorClass)
+	// that move on to the next provider. Any other failure is returned as
+	// authoritative, e.g. a clean "no results" from the primary provider
+	// when ClassNoResults is not listed. Providers without a key, or that do
+	// not support the operation, or whose circuit is open, are always
+	// skipped. It has no effect with Race.
+	FallbackOn []string
+	// SessionToken groups Google autocomplete requests and the GeocodePlace
+	// call that resolves the chosen suggestion into one billing session; see
+	// NewSessionToken. Under a session, Google suggestions are returned
+	// without coordinates (and autocomplete results are not filtered by
+	// Require, MinConfidence or coordinate checks) to keep the session to
+	// one Place Details request.
+	SessionToken string
+	// Raw keeps each provider's JSON response on its results; see
+	// GeocodeResult.Raw.
+	Raw bool
+	// Cache, if set, is consulted before every provider call and filled
+	// with successful results.
+	Cache Cache
+	// Metrics, if set, records every provider call.
+	Metrics *Metrics
+	// Breaker, if set, skips providers that keep failing; see
+	// CircuitBreaker. Cache hits are served even while a circuit is open.
+	Breaker *CircuitBreaker
+	// Logger receives debug records for every request (with credentials
+	// redacted) and response, and the request's connection timings. Nil
+	// disables logging and tracing.
+	Logger *slog.Logger
+	// OnFailure, if set, is called for every provider attempt that fails.
+	OnFailure func(provider string, err error)
+}
+
+// client carries the per-call options down to the provider functions.
+type client struct {
+	opts Options
+}
+
+func newClient(opts Options) *client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = defaultHTTPClient
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.Limit < 1 {
+		opts.Limit = 1
+	}
+	if opts.OSMInterval <= 0 {
+		opts.OSMInterval = DefaultOSMInterval
+	}
+	return &client{opts: opts}
+}
+
+// Key returns the API key for p, preferring o.Keys over p's environment
+// variable. It returns "" when neither is set.
+func (o Options) Key(p Provider) string {
+	return resolveKey(o.Keys, p.Name, p.KeyEnv)
+}
+
+func (c *client) key(name, env string) string {
+	return resolveKey(c.opts.Keys, name, env)
+}
+
+// endpoint returns the URL of path on a provider's API. The base URL
+// (scheme, host and any path prefix) defaults to base and can be replaced
+// per provider through Options.Endpoints or the <NAME>_ENDPOINT variable,
+// e.g. OSM_ENDPOINT for a self-hosted Nominatim.
+func (c *client) endpoint(name, base, path string) string {
+	if override := c.endpointOverride(name); override != "" {
+		base = strings.TrimSuffix(override, "/")
+	}
+	return base + path
+}
+
+// endpointOverride returns the base URL set for a provider through
+// Options.Endpoints or <NAME>_ENDPOINT, or "" when it uses its default.
+func (c *client) endpointOverride(name string) string {
+	if override := c.opts.Endpoints[name]; override != "" {
+		return override
+	}
+	return os.Getenv(strings.ToUpper(name) + "_ENDPOINT")
+}
+
+// searchURLOverride returns the full search URL set in PELIAS_URL or
+// PHOTON_URL, or "" for other providers.
+func searchURLOverride(name string) string {
+	switch name {
+	case "pelias", "photon":
+		return os.Getenv(strings.ToUpper(name) + "_URL")
+	}
+	return ""
+}
+
+// PlainHTTPEndpoint returns the http:// endpoint p is configured with, which
+// RequireHTTPS refuses, or "" when p's requests would use HTTPS.
+func (o Options) PlainHTTPEndpoint(p Provider) string {
+	c := &client{opts: o}
+	for _, u := range []string{c.endpointOverride(p.Name), searchURLOverride(p.Name)} {
+		if strings.HasPrefix(strings.ToLower(u), "http://") {
+			return u
+		}
+	}
+	if p.Name == "positionstack" && !o.RequireHTTPS && c.endpointOverride(p.Name) == "" {
+		return positionstackHTTP
+	}
+	return ""
+}
+
+func resolveKey(keys map[string]string, name, env string) string {
+	if k := keys[name]; k != "" {
+		return k
+	}
+	if env == "" {
+		return ""
+	}
+	return os.Getenv(env)
+}
+
+// providers resolves Options.Providers against the registry.
+func (c *client) providers() ([]Provider, error) {
+	if len(c.opts.Providers) == 0 {
+		return Providers(), nil
+	}
+	var ps []Provider
+	for _, name := range c.opts.Providers {
+		p, ok := LookupProvider(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", name)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+func (c *client) fail(name string, err error) {
+	if c.opts.OnFailure != nil {
+		c.opts.OnFailure(name, err)
+	}
+}
+
+// attempt derives the context for a single provider attempt.
+func (c *client) attempt(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.Timeout > 0 {
+		return context.WithTimeout(ctx, c.opts.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// total derives the context bounding a whole lookup.
+func (c *client) total(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.TotalTimeout > 0 {
+		return context.WithTimeout(ctx, c.opts.TotalTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// ----------- Helper functions -----------
+
+func newResult(provider, address string, lat, lng float64) GeocodeResult {
+	return GeocodeResult{
+		Provider:    provider,
+		Address:     address,
+		Latitude:    lat,
+		Longitude:   lng,
+		Attribution: attributions[provider],
+	}
+}
+
+// limitResults truncates results to at most limit entries.
+func limitResults(results []GeocodeResult, limit int) []GeocodeResult {
+	if limit > 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
+
+// clamp01 limits a provider score to the 0-1 range.
+func clamp01(f float64) float64 {
+	return math.Max(0, math.Min(1, f))
+}
+
+// sortByConfidence orders results from most to least confident, keeping
+// the provider's own order among equal scores.
+func sortByConfidence(results []GeocodeResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Confidence > results[j].Confidence
+	})
+}
+
+// parseCoords parses a coordinate pair that a provider returned as strings.
+// A malformed value is an ErrProvider rather than a silent 0.
+func parseCoords(lat, lng string) (float64, float64, error) {
+	la, err1 := strconv.ParseFloat(lat, 64)
+	ln, err2 := strconv.ParseFloat(lng, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("%w: malformed coordinates %q,%q", ErrProvider, lat, lng)
+	}
+	return finiteCoords(la, ln)
+}
+
+// requireCoords dereferences coordinates decoded into pointer fields. A nil
+// pointer means the provider omitted the field or sent null, which a plain
+// float64 would silently decode as 0 and put the result on Null Island. A
+// pair with only one coordinate is rejected too rather than half-filled.
+func requireCoords(lat, lng *float64) (float64, float64, error) {
+	switch {
+	case lat == nil && lng == nil:
+		return 0, 0, fmt.Errorf("%w: response is missing coordinates", ErrProvider)
+	case lat == nil:
+		return 0, 0, fmt.Errorf("%w: response has a longitude but no latitude", ErrProvider)
+	case lng == nil:
+		return 0, 0, fmt.Errorf("%w: response has a latitude but no longitude", ErrProvider)
+	}
+	return finiteCoords(*lat, *lng)
+}
+
+// requirePair checks that a coordinate array (GeoJSON-style [lng, lat], or
+// Bing's [lat, lng]) holds exactly two values, so a truncated array fails
+// like a missing coordinate instead of dropping the match.
+func requirePair(pair []*float64) error {
+	if len(pair) != 2 {
+		return fmt.Errorf("%w: coordinate array has %d values, expected 2", ErrProvider, len(pair))
+	}
+	return nil
+}
+
+// finiteCoords rejects NaN and infinite coordinates, which string-encoded
+// responses can carry ("NaN", "Infinity") even though JSON numbers cannot.
+func finiteCoords(lat, lng float64) (float64, float64, error) {
+	if math.IsNaN(lat) || math.IsInf(lat, 0) || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		return 0, 0, fmt.Errorf("%w: non-finite coordinates %v,%v", ErrProvider, lat, lng)
+	}
+	return lat, lng, nil
+}
+
+// ----------- Lookup -----------
+
+// lookupRequest describes a single forward or reverse geocoding query.
+type lookupRequest struct {
+	address      string
+	lat, lng     float64
+	reverse      bool
+	autocomplete bool               // suggestions for partial input; see Autocomplete
+	structured   *StructuredAddress // separate fields; address holds them joined
+}
+
+// lookup runs req against a single provider, consulting Options.Cache first.
+// Forward results are filtered by Options.Require and Options.MinConfidence
+// after the cache, so cached entries do not depend on either. Autocomplete
+// results under Options.SessionToken are left unfiltered.
+func (c *client) lookup(ctx context.Context, p Provider, req lookupRequest) ([]GeocodeResult, error) {
+	results, err := c.cachedQuery(ctx, p, req)
+	if err != nil || req.reverse || req.autocomplete && c.opts.SessionToken != "" {
+		return results, err
+	}
+	if results, err = c.filterInvalid(results); err != nil {
+		return nil, err
+	}
+	if results, err = c.filterRequired(results); err != nil {
+		return nil, err
+	}
+	return c.filterConfidence(results)
+}
+
+// filterInvalid drops results whose coordinates are out of range (or 0,0
+// with Options.RejectNullIsland), failing with ErrProvider when none remain.
+func (c *client) filterInvalid(results []GeocodeResult) ([]GeocodeResult, error) {
+	var kept []GeocodeResult
+	for _, r := range results {
+		if reason := c.invalidCoordinate(r.Latitude, r.Longitude); reason != "" {
+			c.logger().Debug("dropped result", "provider", r.Provider, "lat", r.Latitude, "lng", r.Longitude, "reason", reason)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%w: invalid coordinates %g,%g", ErrProvider, results[0].Latitude, results[0].Longitude)
+	}
+	return kept, nil
+}
+
+// invalidCoordinate describes why a coordinate is unusable, or returns "".
+func (c *client) invalidCoordinate(lat, lng float64) string {
+	switch {
+	case math.IsNaN(lat) || math.IsNaN(lng):
+		return "not a number"
+	case lat < -90 || lat > 90 || lng < -180 || lng > 180:
+		return "out of range"
+	case c.opts.RejectNullIsland && lat == 0 && lng == 0:
+		return "null island"
+	}
+	return ""
+}
+
+// cachedQuery calls query through Options.Cache. Results are copied in and
+// out of the cache so callers may modify them.
+func (c *client) cachedQuery(ctx context.Context, p Provider, req lookupRequest) ([]GeocodeResult, error) {
+	if c.opts.Cache == nil {
+		return c.query(ctx, p, req)
+	}
+	key := c.cacheKey(p, req)
+	if results, ok := c.opts.Cache.Get(key); ok {
+		return append([]GeocodeResult(nil), results...), nil
+	}
+	results, err := c.query(ctx, p, req)
+	if err != nil {
+		return nil, err
+	}
+	c.opts.Cache.Put(key, append([]GeocodeResult(nil), results...))
+	return results, nil
+}
+
+// query calls the provider for req, recording the attempt in
+// Options.Metrics and Options.Breaker.
+func (c *client) query(ctx context.Context, p Provider, req lookupRequest) ([]GeocodeResult, error) {
+	if err := c.opts.Breaker.allow(p.Name); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if c.opts.Raw {
+		ctx = context.WithValue(ctx, rawKey{}, &raw)
+	}
+	start := time.Now()
+	results, err := c.call(ctx, p, req)
+	c.opts.Metrics.observe(p.Name, time.Since(start), err)
+	c.opts.Breaker.record(p.Name, err)
+	for i := range results {
+		results[i].Raw = raw
+	}
+	return results, err
+}
+
+func (c *client) call(ctx context.Context, p Provider, req lookupRequest) ([]GeocodeResult, error) {
+	if req.reverse {
+		if !p.SupportsReverse() {
+			return nil, fmt.Errorf("reverse %w", ErrUnsupported)
+		}
+		formatted, err := p.reverse(ctx, c, req.lat, req.lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult(p.Name, formatted, req.lat, req.lng)
+		res.Formatted = formatted
+		return []GeocodeResult{res}, nil
+	}
+	search := p.geocode
+	if ac := autocompleters[p.Name]; req.autocomplete && ac != nil {
+		search = ac
+	}
+	if ss := structuredSearchers[p.Name]; req.structured != nil && ss != nil {
+		search = func(ctx context.Context, c *client, _ string, limit int) ([]GeocodeResult, error) {
+			return ss(ctx, c, *req.structured, limit)
+		}
+	}
+	results, err := search(ctx, c, req.address, c.opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	sortByConfidence(results)
+	return results, nil
+}
+
+// filterConfidence drops results below Options.MinConfidence, failing with
+// ErrLowConfidence when none remain. Results without a confidence score
+// (zero) are kept.
+func (c *client) filterConfidence(results []GeocodeResult) ([]GeocodeResult, error) {
+	if c.opts.MinConfidence <= 0 {
+		return results, nil
+	}
+	var kept []GeocodeResult
+	for _, r := range results {
+		if r.Confidence == 0 || r.Confidence >= c.opts.MinConfidence {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%w (best %.2f, minimum %.2f)", ErrLowConfidence, results[0].Confidence, c.opts.MinConfidence)
+	}
+	return kept, nil
+}
+
+func (c *client) run(ctx context.Context, req lookupRequest) ([]GeocodeResult, error) {
+	providers, err := c.providers()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.total(ctx)
+	defer cancel()
+	if c.opts.Race {
+		return c.lookupRace(ctx, providers, req)
+	}
+	return c.lookupSequential(ctx, providers, req)
+}
+
+// lookupSequential tries providers in order until one succeeds.
+func (c *client) lookupSequential(ctx context.Context, providers []Provider, req lookupRequest) ([]GeocodeResult, error) {
+	failed := &AllFailedError{}
+	for _, p := range providers {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("gave up before trying %s: %w", p.Name, err)
+		}
+		actx, cancel := c.attempt(ctx)
+		results, err := c.lookup(actx, p, req)
+		cancel()
+		if err != nil && (c.opts.FailFast || !c.fallsBack(err)) {
+			return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+		}
+		if err != nil {
+			c.fail(p.Name, err)
+			failed.add(p.Name, err)
+			continue
+		}
+		return results, nil
+	}
+	return nil, failed
+}
+
+// fallsBack reports whether Options.FallbackOn lets a failure with err move
+// on to the next provider.
+func (c *client) fallsBack(err error) bool {
+	if len(c.opts.FallbackOn) == 0 || errors.Is(err, ErrMissingKey) || errors.Is(err, ErrUnsupported) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	return slices.Contains(c.opts.FallbackOn, ErrorClass(err))
+}
+
+// lookupRace queries all providers concurrently and returns the first
+// successful answer. The remaining requests are cancelled once a winner is
+// found; the result channel is buffered so late finishers never block.
+func (c *client) lookupRace(ctx context.Context, providers []Provider, req lookupRequest) ([]GeocodeResult, error) {
+	type outcome struct {
+		name    string
+		results []GeocodeResult
+		err     error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan outcome, len(providers))
+	for _, p := range providers {
+		go func(p Provider) {
+			actx, acancel := c.attempt(ctx)
+			defer acancel()
+			results, err := c.lookup(actx, p, req)
+			ch <- outcome{p.Name, results, err}
+		}(p)
+	}
+
+	failed := &AllFailedError{}
+	for range providers {
+		o := <-ch
+		if o.err != nil {
+			c.fail(o.name, o.err)
+			failed.add(o.name, o.err)
+			continue
+		}
+		return o.results, nil
+	}
+	return nil, failed
+}
+
+// ----------- Entry points -----------
+
+// Geocode resolves address to the best match of the first provider that
+// succeeds.
+func Geocode(ctx context.Context, address string, opts Options) (GeocodeResult, error) {
+	opts.Limit = 1
+	results, err := GeocodeMany(ctx, address, opts)
+	if err != nil {
+		return GeocodeResult{}, err
+	}
+	return results[0], nil
+}
+
+// GeocodeMany resolves address to up to opts.Limit matches from the first
+// provider that succeeds.
+// The query sent to providers is normalized (see NormalizeAddress), but each
+// result's Address keeps the caller's original input.
+func GeocodeMany(ctx context.Context, address string, opts Options) ([]GeocodeResult, error) {
+	c := newClient(opts)
+	results, err := c.run(ctx, lookupRequest{address: NormalizeAddress(address, c.opts.ExpandAbbreviations)})
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Address = address
+	}
+	return results, nil
+}
+
+// Reverse resolves a coordinate to the formatted address reported by the
+// first provider that succeeds. Providers without reverse support are
+// skipped.
+func Reverse(ctx context.Context, lat, lng float64, opts Options) (GeocodeResult, error) {
+	results, err := newClient(opts).run(ctx, lookupRequest{lat: lat, lng: lng, reverse: true})
+	if err != nil {
+		return GeocodeResult{}, err
+	}
+	return results[0], nil
+}
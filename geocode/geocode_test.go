@@ -0,0 +1,113 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubProvider serves body for every request and returns options that send
+// provider's lookups to it.
+func stubProvider(t *testing.T, provider, body string) Options {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return Options{
+		Providers: []string{provider},
+		Endpoints: map[string]string{provider: srv.URL},
+		Keys:      map[string]string{provider: "test-key"},
+		FailFast:  true,
+	}
+}
+
+func TestMalformedCoordinatesAreProviderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		body     string
+	}{
+		{"osm NaN latitude", "osm", `[{"lat":"NaN","lon":"13.4"}]`},
+		{"osm +Inf longitude", "osm", `[{"lat":"52.5","lon":"Infinity"}]`},
+		{"osm -Inf latitude", "osm", `[{"lat":"-Inf","lon":"13.4"}]`},
+		{"osm missing latitude", "osm", `[{"lon":"13.4"}]`},
+		{"google missing latitude", "google", `{"status":"OK","results":[{"geometry":{"location":{"lng":13.4}}}]}`},
+		{"google missing longitude", "google", `{"status":"OK","results":[{"geometry":{"location":{"lat":52.5}}}]}`},
+		{"google null coordinates", "google", `{"status":"OK","results":[{"geometry":{"location":{"lat":null,"lng":null}}}]}`},
+		{"mapbox partial center", "mapbox", `{"features":[{"center":[12.5],"place_name":"x"}]}`},
+		{"yandex malformed pos", "yandex", `{"response":{"GeoObjectCollection":{"featureMember":[{"GeoObject":{"Point":{"pos":"abc"}}}]}}}`},
+		{"yandex NaN pos", "yandex", `{"response":{"GeoObjectCollection":{"featureMember":[{"GeoObject":{"Point":{"pos":"NaN 52.5"}}}]}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := stubProvider(t, tt.provider, tt.body)
+			_, err := Geocode(context.Background(), "Berlin", opts)
+			if !errors.Is(err, ErrProvider) {
+				t.Fatalf("Geocode error = %v, want ErrProvider", err)
+			}
+			if class := ErrorClass(err); class != ClassProvider {
+				t.Errorf("ErrorClass = %q, want %q", class, ClassProvider)
+			}
+		})
+	}
+}
+
+func TestWellFormedCoordinates(t *testing.T) {
+	opts := stubProvider(t, "osm", `[{"lat":"52.5","lon":"13.4","display_name":"Berlin"}]`)
+	res, err := Geocode(context.Background(), "Berlin", opts)
+	if err != nil {
+		t.Fatalf("Geocode: %v", err)
+	}
+	if res.Latitude != 52.5 || res.Longitude != 13.4 {
+		t.Errorf("got %v,%v, want 52.5,13.4", res.Latitude, res.Longitude)
+	}
+}
+
+func TestSeaLevelElevationIsKept(t *testing.T) {
+	zero := 0.0
+	for _, tt := range []struct {
+		elevation *float64
+		want      bool
+	}{
+		{&zero, true},
+		{nil, false},
+	} {
+		b, err := json.Marshal(GeocodeResult{Provider: "osm", Elevation: tt.elevation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(b), `"elevation":0`); got != tt.want {
+			t.Errorf("Marshal(elevation %v) = %s", tt.elevation, b)
+		}
+	}
+}
+
+func TestPlainHTTPEndpoint(t *testing.T) {
+	positionstack, _ := LookupProvider("positionstack")
+	osm, _ := LookupProvider("osm")
+	tests := []struct {
+		name string
+		p    Provider
+		opts Options
+		want string
+	}{
+		{"positionstack free plan default", positionstack, Options{}, "http://api.positionstack.com"},
+		{"positionstack switched to HTTPS", positionstack, Options{RequireHTTPS: true}, ""},
+		{"positionstack HTTPS override", positionstack, Options{Endpoints: map[string]string{"positionstack": "https://ps.example"}}, ""},
+		{"plain HTTP override", osm, Options{RequireHTTPS: true, Endpoints: map[string]string{"osm": "http://nominatim.local"}}, "http://nominatim.local"},
+		{"HTTPS default", osm, Options{RequireHTTPS: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.PlainHTTPEndpoint(tt.p); got != tt.want {
+				t.Errorf("PlainHTTPEndpoint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
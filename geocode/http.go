@@ -0,0 +1,296 @@
+package geocode
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHTTPClient is shared by all lookups that do not supply their own
+// client, so connections are reused across providers and calls.
+var defaultHTTPClient = &http.Client{Transport: newTransport()}
+
+// newTransport returns a transport tuned for many small requests to a
+// handful of hosts.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// Stated explicitly: HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply by default.
+	t.Proxy = http.ProxyFromEnvironment
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 10
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+// NewHTTPClient returns a client with a connection-reusing transport. A
+// non-empty proxyURL routes requests through that proxy; otherwise the
+// standard HTTP_PROXY/HTTPS_PROXY variables apply. Either way, hosts listed
+// in NO_PROXY are reached directly. insecure disables TLS certificate
+// verification and should only be used for debugging.
+func NewHTTPClient(proxyURL string, insecure bool) (*http.Client, error) {
+	t := newTransport()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", proxyURL, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: scheme and host required", proxyURL)
+		}
+		noProxy := noProxyHosts()
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return u, nil
+		}
+	}
+	if insecure {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// noProxyHosts returns the entries of NO_PROXY (or no_proxy).
+func noProxyHosts() []string {
+	v := os.Getenv("NO_PROXY")
+	if v == "" {
+		v = os.Getenv("no_proxy")
+	}
+	var hosts []string
+	for _, h := range strings.Split(v, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, strings.ToLower(h))
+		}
+	}
+	return hosts
+}
+
+// bypassProxy reports whether host matches a NO_PROXY entry: "*", the exact
+// host, or a domain ("example.com" or ".example.com") it belongs to. Ports
+// in entries are ignored.
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		if entry == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// rawKey is the context key under which query collects the response body
+// for Options.Raw.
+type rawKey struct{}
+
+// retryBaseDelay is the backoff before the first retry; it doubles on every
+// subsequent attempt.
+const retryBaseDelay = 250 * time.Millisecond
+
+// getJSON performs a GET request bound to ctx and decodes the JSON body into
+// out. Rate-limit (429) and server (5xx) responses as well as network errors
+// are retried up to Options.Retries times with jittered exponential backoff,
+// or after the delay a 429 or 503 response asks for in Retry-After.
+func (c *client) getJSON(ctx context.Context, query string, header http.Header, out any) error {
+	if c.opts.RequireHTTPS {
+		if u, err := url.Parse(query); err == nil && u.Scheme != "https" {
+			return fmt.Errorf("%w: plain HTTP endpoint %s refused (HTTPS required)", ErrUnsupported, redactURL(u))
+		}
+	}
+	var lastErr error
+	retryAfter := time.Duration(-1)
+	for attempt := 0; attempt <= c.opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := c.sleepBackoff(ctx, attempt, retryAfter); err != nil {
+				return lastErr
+			}
+		}
+
+		status, respHeader, body, err := c.do(ctx, query, header)
+		retryAfter = -1
+		if err != nil {
+			// A retry cannot get a rate limit slot the first try missed.
+			if ctx.Err() != nil || errors.Is(err, errThrottled) {
+				return err
+			}
+			lastErr = err
+			continue
+		}
+		if status < 200 || status > 299 {
+			lastErr = newHTTPError(status, body)
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				if d, ok := parseRetryAfter(respHeader.Get("Retry-After"), time.Now()); ok {
+					retryAfter = d
+				}
+			}
+			if status == http.StatusTooManyRequests || status >= 500 {
+				continue
+			}
+			return lastErr
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("%w: invalid JSON response: %v", ErrProvider, err)
+		}
+		if raw, ok := ctx.Value(rawKey{}).(*json.RawMessage); ok {
+			*raw = redactBody(body, query)
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// do sends a single request and returns the status code, response headers
+// and full body.
+func (c *client) do(ctx context.Context, query string, header http.Header) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", query, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.opts.QPS > 0 {
+		if err := waitLimiter(ctx, globalLimiter, time.Duration(float64(time.Second)/c.opts.QPS)); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if req.URL.Host == osmHost {
+		if err := waitLimiter(ctx, osmLimiter, c.opts.OSMInterval); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	log := c.logger()
+	log.Debug("request", "url", redactURL(req.URL))
+	var timing *requestTiming
+	if c.opts.Logger != nil {
+		timing = newRequestTiming()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timing.trace()))
+	}
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		// The transport error embeds the full URL; keep the key out of it.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = redactURL(req.URL)
+		}
+		log.Debug("request failed", "url", redactURL(req.URL), "error", err)
+		c.logTiming(req.URL, timing)
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	log.Debug("response", "url", redactURL(req.URL), "status", resp.StatusCode, "bytes", len(body), "body", snippet(body))
+	c.logTiming(req.URL, timing)
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// logTiming logs where the time of one request went, if it was traced.
+func (c *client) logTiming(u *url.URL, timing *requestTiming) {
+	if timing == nil {
+		return
+	}
+	c.logger().Debug("timing", append([]any{"url", redactURL(u)}, timing.attrs()...)...)
+}
+
+// parseRetryAfter parses a Retry-After header, given either as seconds or
+// as an HTTP date, into the delay from now. A date in the past means no
+// wait.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// randMu serializes calls to Options.Rand, which is not safe for concurrent
+// use on its own.
+var randMu sync.Mutex
+
+// jitter returns a random duration in [0, max), drawn from Options.Rand if
+// set.
+func (c *client) jitter(max time.Duration) time.Duration {
+	if c.opts.Rand == nil {
+		return time.Duration(rand.Int63n(int64(max)))
+	}
+	randMu.Lock()
+	defer randMu.Unlock()
+	return time.Duration(c.opts.Rand.Int63n(int64(max)))
+}
+
+// sleepBackoff waits before the given retry attempt: for retryAfter, capped
+// at Options.MaxRetryAfter, if the provider asked for a delay (retryAfter
+// >= 0), otherwise with jittered exponential backoff. It returns early with
+// an error if ctx ends first or its deadline leaves no room for the wait.
+func (c *client) sleepBackoff(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	var delay time.Duration
+	switch {
+	case retryAfter >= 0 && c.opts.MaxRetryAfter > 0:
+		delay = min(retryAfter, c.opts.MaxRetryAfter)
+	case retryAfter >= 0:
+		delay = retryAfter
+	default:
+		delay = retryBaseDelay << (attempt - 1)
+		delay += c.jitter(delay / 2)
+	}
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return errors.New("no time left for retry")
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// osmHeader returns the preferred result language for Nominatim, which
+// takes it as a header. The User-Agent its usage policy requires is set on
+// every request by do.
+func (c *client) osmHeader() http.Header {
+	h := http.Header{}
+	if c.opts.Language != "" {
+		h.Set("Accept-Language", c.opts.Language)
+	}
+	return h
+}
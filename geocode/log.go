@@ -0,0 +1,87 @@
+package geocode
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// discardLogger is used when Options.Logger is nil.
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func (c *client) logger() *slog.Logger {
+	if c.opts.Logger == nil {
+		return discardLogger
+	}
+	return c.opts.Logger
+}
+
+// secretParams are query parameters that carry credentials and must never
+// appear in logs.
+var secretParams = map[string]bool{
+	"key":          true,
+	"apikey":       true,
+	"api_key":      true,
+	"access_key":   true,
+	"access_token": true,
+	"token":        true,
+	"username":     true, // GeoNames account name
+}
+
+// redactURL renders u with the values of credential parameters replaced.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	redacted := false
+	for name := range q {
+		if secretParams[strings.ToLower(name)] {
+			q.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	r := *u
+	r.RawQuery = q.Encode()
+	return r.String()
+}
+
+// redactBody replaces any credential from the request URL that the provider
+// echoed back in body, e.g. in a "next page" link or a request summary.
+func redactBody(body []byte, query string) []byte {
+	u, err := url.Parse(query)
+	if err != nil {
+		return body
+	}
+	for name, values := range u.Query() {
+		if !secretParams[strings.ToLower(name)] {
+			continue
+		}
+		for _, v := range values {
+			if v != "" {
+				body = bytes.ReplaceAll(body, []byte(v), []byte("REDACTED"))
+			}
+		}
+	}
+	return body
+}
+
+// maxSnippet bounds how much of a response body is logged.
+const maxSnippet = 512
+
+// snippet returns the start of a response body for logging.
+func snippet(body []byte) string {
+	if len(body) <= maxSnippet {
+		return string(body)
+	}
+	return string(body[:maxSnippet]) + "..."
+}
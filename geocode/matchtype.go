@@ -0,0 +1,96 @@
+package geocode
+
+import "strings"
+
+// Match types describe how precisely a result pins down the address,
+// normalized from each provider's own granularity indicator.
+const (
+	MatchRooftop      = "rooftop"      // the building or address point itself
+	MatchInterpolated = "interpolated" // estimated along a street's number range
+	MatchStreet       = "street"       // a street or intersection
+	MatchLocality     = "locality"     // a postcode, city or larger area
+	MatchUnknown      = "unknown"      // reported, but not recognized
+)
+
+// localityTypes are area-sized place types shared by Google, Nominatim and
+// OpenCage.
+var localityTypes = map[string]bool{
+	"neighbourhood": true, "neighborhood": true, "suburb": true, "quarter": true,
+	"hamlet": true, "village": true, "town": true, "city": true, "locality": true,
+	"municipality": true, "county": true, "state": true, "region": true,
+	"postcode": true, "postal_code": true, "country": true,
+	"administrative_area_level_1": true, "administrative_area_level_2": true,
+	"sublocality": true, "political": true,
+}
+
+// googleMatchType maps Google's geometry.location_type, falling back to the
+// result types for the approximate kinds.
+func googleMatchType(locationType string, types []string) string {
+	switch locationType {
+	case "ROOFTOP":
+		return MatchRooftop
+	case "RANGE_INTERPOLATED":
+		return MatchInterpolated
+	}
+	for _, t := range types {
+		switch {
+		case t == "route" || t == "intersection":
+			return MatchStreet
+		case localityTypes[t]:
+			return MatchLocality
+		}
+	}
+	if locationType == "" {
+		return ""
+	}
+	return MatchUnknown
+}
+
+// osmMatchType maps a Nominatim (or LocationIQ) class and type.
+func osmMatchType(class, typ string) string {
+	switch {
+	case class == "" && typ == "":
+		return ""
+	case class == "building" || typ == "house" || typ == "house_number":
+		return MatchRooftop
+	case class == "highway":
+		return MatchStreet
+	case class == "boundary" || class == "place" && localityTypes[typ]:
+		return MatchLocality
+	}
+	return MatchUnknown
+}
+
+// openCageMatchType maps OpenCage's components._type.
+func openCageMatchType(typ string) string {
+	switch {
+	case typ == "":
+		return ""
+	case typ == "building" || typ == "house":
+		return MatchRooftop
+	case typ == "road":
+		return MatchStreet
+	case localityTypes[typ]:
+		return MatchLocality
+	}
+	return MatchUnknown
+}
+
+// mapQuestMatchType maps the granularity letter that starts MapQuest's
+// geocodeQualityCode ("P1AAA", "L1AAA", ...).
+func mapQuestMatchType(code string) string {
+	if code == "" {
+		return ""
+	}
+	switch strings.ToUpper(code[:1]) {
+	case "P":
+		return MatchRooftop
+	case "L":
+		return MatchInterpolated
+	case "I", "B":
+		return MatchStreet
+	case "A", "Z":
+		return MatchLocality
+	}
+	return MatchUnknown
+}
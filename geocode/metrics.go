@@ -0,0 +1,102 @@
+package geocode
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histogram.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics counts provider attempts, successes and failures and records
+// their latency. It is safe for concurrent use; the zero value is ready.
+type Metrics struct {
+	mu        sync.Mutex
+	providers map[string]*providerMetrics
+}
+
+type providerMetrics struct {
+	attempts  uint64
+	successes uint64
+	failures  uint64
+	buckets   []uint64 // cumulative counts per latencyBuckets entry
+	sum       float64
+}
+
+// observe records one provider call.
+func (m *Metrics) observe(provider string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.providers == nil {
+		m.providers = make(map[string]*providerMetrics)
+	}
+	pm := m.providers[provider]
+	if pm == nil {
+		pm = &providerMetrics{buckets: make([]uint64, len(latencyBuckets))}
+		m.providers[provider] = pm
+	}
+	pm.attempts++
+	if err != nil {
+		pm.failures++
+	} else {
+		pm.successes++
+	}
+	secs := d.Seconds()
+	pm.sum += secs
+	for i, le := range latencyBuckets {
+		if secs <= le {
+			pm.buckets[i]++
+		}
+	}
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.providers))
+	for name := range m.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	counter := func(metric, help string, value func(*providerMetrics) uint64) {
+		printf("# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			printf("%s{provider=%q} %d\n", metric, name, value(m.providers[name]))
+		}
+	}
+	counter("geolooker_provider_attempts_total", "Provider lookups attempted.",
+		func(pm *providerMetrics) uint64 { return pm.attempts })
+	counter("geolooker_provider_successes_total", "Provider lookups that returned a result.",
+		func(pm *providerMetrics) uint64 { return pm.successes })
+	counter("geolooker_provider_failures_total", "Provider lookups that failed.",
+		func(pm *providerMetrics) uint64 { return pm.failures })
+
+	const hist = "geolooker_provider_request_duration_seconds"
+	printf("# HELP %s Provider lookup latency.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		pm := m.providers[name]
+		for i, le := range latencyBuckets {
+			printf("%s_bucket{provider=%q,le=\"%g\"} %d\n", hist, name, le, pm.buckets[i])
+		}
+		printf("%s_bucket{provider=%q,le=\"+Inf\"} %d\n", hist, name, pm.attempts)
+		printf("%s_sum{provider=%q} %g\n", hist, name, pm.sum)
+		printf("%s_count{provider=%q} %d\n", hist, name, pm.attempts)
+	}
+	return err
+}
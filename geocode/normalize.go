@@ -0,0 +1,71 @@
+package geocode
+
+import "strings"
+
+// streetAbbreviations maps common street-type abbreviations (lowercase,
+// without the trailing period) to their expansion. Forms such as "St"
+// (Saint), "Dr" (Doctor), "CT" (Connecticut) or "PL" (Poland) are ambiguous
+// elsewhere in an address, so NormalizeAddress only expands the street-type
+// position.
+var streetAbbreviations = map[string]string{
+	"st":   "Street",
+	"ave":  "Avenue",
+	"av":   "Avenue",
+	"rd":   "Road",
+	"blvd": "Boulevard",
+	"dr":   "Drive",
+	"ln":   "Lane",
+	"ct":   "Court",
+	"pl":   "Place",
+	"sq":   "Square",
+	"ter":  "Terrace",
+	"cir":  "Circle",
+	"hwy":  "Highway",
+	"pkwy": "Parkway",
+}
+
+// NormalizeAddress trims an address and collapses internal whitespace. With
+// expand set it also spells out a street-type abbreviation from
+// streetAbbreviations in the last word of the street, i.e. of the part
+// before the first comma, so "123 Main St., Springfield" becomes "123 Main
+// Street, Springfield" while "St. Louis, MO" is kept.
+func NormalizeAddress(address string, expand bool) string {
+	words := strings.Fields(address)
+	if expand {
+		// A street needs a name before its type.
+		if i := streetTypeIndex(words); i > 0 {
+			words[i] = expandAbbreviation(words[i])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// streetTypeIndex returns the index of the last word before the first
+// comma, or of the last word when there is none.
+func streetTypeIndex(words []string) int {
+	for i, w := range words {
+		if strings.HasSuffix(w, ",") {
+			return i
+		}
+	}
+	return len(words) - 1
+}
+
+// QueryKey returns the form under which lookups of address are identical:
+// the normalized address, case-folded, as used in cache keys. Batch callers
+// use it to geocode repeated addresses once.
+func QueryKey(address string, expand bool) string {
+	return strings.ToLower(NormalizeAddress(address, expand))
+}
+
+// expandAbbreviation expands a single word, keeping any trailing comma.
+func expandAbbreviation(word string) string {
+	suffix := ""
+	if strings.HasSuffix(word, ",") {
+		word, suffix = word[:len(word)-1], ","
+	}
+	if full, ok := streetAbbreviations[strings.ToLower(strings.TrimSuffix(word, "."))]; ok {
+		return full + suffix
+	}
+	return word + suffix
+}
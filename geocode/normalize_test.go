@@ -0,0 +1,33 @@
+package geocode
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"  123   Main St.  ", "123 Main Street"},
+		{"123 main st, Springfield", "123 main Street, Springfield"},
+		{"10 Downing Ave., London", "10 Downing Avenue, London"},
+		{"1 Market Sq", "1 Market Square"},
+		// Saint, state codes, Doctor and postal prefixes stay as written.
+		{"St. Louis, MO", "St. Louis, MO"},
+		{"Hartford, CT", "Hartford, CT"},
+		{"Dr Martin Luther King Jr Blvd, Atlanta", "Dr Martin Luther King Jr Boulevard, Atlanta"},
+		{"ul. Marszałkowska 1, 00-001 Warszawa, PL", "ul. Marszałkowska 1, 00-001 Warszawa, PL"},
+		{"5 Elm Ct, Hartford, CT", "5 Elm Court, Hartford, CT"},
+		{"St", "St"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAddress(tt.in, true); got != tt.want {
+			t.Errorf("NormalizeAddress(%q, true) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAddressWithoutExpansion(t *testing.T) {
+	if got := NormalizeAddress(" 123  Main St. ", false); got != "123 Main St." {
+		t.Errorf("NormalizeAddress = %q, want %q", got, "123 Main St.")
+	}
+}
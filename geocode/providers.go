@@ -0,0 +1,1068 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ----------- Registry -----------
+
+// Provider describes a supported geocoding service.
+type Provider struct {
+	Name string
+	// KeyEnv is the environment variable holding the provider's API key, or
+	// empty for providers that need no key.
+	KeyEnv string
+
+	geocode func(context.Context, *client, string, int) ([]GeocodeResult, error)
+	// reverse is nil for providers without a reverse geocoding endpoint.
+	reverse func(context.Context, *client, float64, float64) (string, error)
+}
+
+// NeedsKey reports whether the provider requires an API key.
+func (p Provider) NeedsKey() bool {
+	return p.KeyEnv != ""
+}
+
+// optionalKeyEnvs holds the key variables of providers that work without a
+// key but send one when it is set.
+var optionalKeyEnvs = map[string]string{
+	"pelias": "PELIAS_API_KEY",
+}
+
+// OptionalKeyEnv returns the environment variable of a key the provider
+// sends when set but does not require, or "".
+func (p Provider) OptionalKeyEnv() string {
+	return optionalKeyEnvs[p.Name]
+}
+
+// SupportsReverse reports whether the provider can reverse geocode.
+func (p Provider) SupportsReverse() bool {
+	return p.reverse != nil
+}
+
+// registry lists every provider in the default fallback order.
+var registry = []Provider{
+	{"google", "GOOGLE_API_KEY", geocodeGoogle, reverseGoogle},
+	{"positionstack", "POSITIONSTACK_KEY", geocodePositionstack, nil},
+	{"opencage", "OPENCAGE_KEY", geocodeOpenCage, reverseOpenCage},
+	{"locationiq", "LOCATIONIQ_KEY", geocodeLocationIQ, nil},
+	{"mapquest", "MAPQUEST_KEY", geocodeMapQuest, nil},
+	{"mapbox", "MAPBOX_TOKEN", geocodeMapbox, nil},
+	{"here", "HERE_API_KEY", geocodeHere, nil},
+	{"tomtom", "TOMTOM_KEY", geocodeTomTom, nil},
+	{"bing", "BING_MAPS_KEY", geocodeBing, nil},
+	{"yandex", "YANDEX_KEY", geocodeYandex, nil},
+	{"geonames", "GEONAMES_USERNAME", geocodeGeoNames, nil}, // a username, not a key
+	{"geocodio", "GEOCODIO_KEY", geocodeGeocodio, nil},
+	{"osm", "", geocodeOSM, reverseOSM},
+	{"photon", "", geocodePhoton, nil},
+	{"census", "", geocodeCensus, nil},
+	{"pelias", "", geocodePelias, nil},
+}
+
+// Providers returns all supported providers in the default fallback order.
+func Providers() []Provider {
+	return append([]Provider(nil), registry...)
+}
+
+// LookupProvider returns the provider with the given name.
+func LookupProvider(name string) (Provider, bool) {
+	for _, p := range registry {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// ----------- Response structs -----------
+
+type GoogleGeocodeResponse struct {
+	Results []struct {
+		FormattedAddress  string   `json:"formatted_address"`
+		Types             []string `json:"types"`
+		AddressComponents []struct {
+			LongName  string   `json:"long_name"`
+			ShortName string   `json:"short_name"`
+			Types     []string `json:"types"`
+		} `json:"address_components"`
+		Geometry struct {
+			Location struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			} `json:"location"`
+			LocationType string `json:"location_type"`
+		} `json:"geometry"`
+	} `json:"results"`
+	Status string `json:"status"`
+}
+
+type OSMGeocodeResponse []struct {
+	Licence     string            `json:"licence"`
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	Class       string            `json:"class"`
+	Type        string            `json:"type"`
+	Importance  float64           `json:"importance"`
+	DisplayName string            `json:"display_name"`
+	Address     map[string]string `json:"address"`
+}
+
+type PositionstackResponse struct {
+	Data []struct {
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+		Label     string   `json:"label"`
+	} `json:"data"`
+}
+
+type OSMReverseResponse struct {
+	DisplayName string `json:"display_name"`
+	Error       string `json:"error"`
+}
+
+type OpenCageResponse struct {
+	Licenses []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"licenses"`
+	Results []struct {
+		Formatted  string         `json:"formatted"`
+		Components map[string]any `json:"components"`
+		Confidence int            `json:"confidence"` // 0-10
+		Geometry   struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		} `json:"geometry"`
+	} `json:"results"`
+}
+
+type LocationIQResponse []struct {
+	Licence     string            `json:"licence"`
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	Class       string            `json:"class"`
+	Type        string            `json:"type"`
+	Importance  float64           `json:"importance"`
+	DisplayName string            `json:"display_name"`
+	Address     map[string]string `json:"address"`
+}
+
+type MapQuestResponse struct {
+	Results []struct {
+		Locations []struct {
+			LatLng struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			} `json:"latLng"`
+			GeocodeQuality     string `json:"geocodeQuality"`
+			GeocodeQualityCode string `json:"geocodeQualityCode"`
+		} `json:"locations"`
+	} `json:"results"`
+	Info struct {
+		Statuscode int      `json:"statuscode"`
+		Messages   []string `json:"messages"`
+	} `json:"info"`
+}
+
+type MapboxResponse struct {
+	Attribution string `json:"attribution"`
+	Features    []struct {
+		Center    []*float64 `json:"center"` // [lng, lat]
+		Relevance float64    `json:"relevance"`
+		PlaceName string     `json:"place_name"`
+	} `json:"features"`
+}
+
+type HereResponse struct {
+	Items []struct {
+		Position struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		} `json:"position"`
+		Scoring struct {
+			QueryScore float64 `json:"queryScore"`
+		} `json:"scoring"`
+		Address map[string]any `json:"address"`
+	} `json:"items"`
+}
+
+type TomTomResponse struct {
+	Results []struct {
+		Position struct {
+			Lat *float64 `json:"lat"`
+			Lon *float64 `json:"lon"`
+		} `json:"position"`
+		Address map[string]any `json:"address"`
+	} `json:"results"`
+}
+
+type BingResponse struct {
+	Copyright    string `json:"copyright"`
+	ResourceSets []struct {
+		Resources []struct {
+			Point struct {
+				Coordinates []*float64 `json:"coordinates"` // [lat, lng]
+			} `json:"point"`
+			Address    map[string]any `json:"address"`
+			Confidence string         `json:"confidence"`
+		} `json:"resources"`
+	} `json:"resourceSets"`
+}
+
+type YandexResponse struct {
+	Response struct {
+		GeoObjectCollection struct {
+			FeatureMember []struct {
+				GeoObject struct {
+					MetaDataProperty struct {
+						GeocoderMetaData struct {
+							Precision string `json:"precision"`
+							Text      string `json:"text"`
+							Address   struct {
+								Components []struct {
+									Kind string `json:"kind"`
+									Name string `json:"name"`
+								} `json:"Components"`
+							} `json:"Address"`
+						} `json:"GeocoderMetaData"`
+					} `json:"metaDataProperty"`
+					Point struct {
+						Pos string `json:"pos"` // "lng lat"
+					} `json:"Point"`
+				} `json:"GeoObject"`
+			} `json:"featureMember"`
+		} `json:"GeoObjectCollection"`
+	} `json:"response"`
+}
+
+type PeliasResponse struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []*float64 `json:"coordinates"` // [lng, lat]
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+type PhotonResponse struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []*float64 `json:"coordinates"` // [lng, lat]
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+type CensusResponse struct {
+	Result struct {
+		AddressMatches []struct {
+			MatchedAddress string `json:"matchedAddress"`
+			Coordinates    struct {
+				X *float64 `json:"x"` // longitude
+				Y *float64 `json:"y"` // latitude
+			} `json:"coordinates"`
+			AddressComponents map[string]any `json:"addressComponents"`
+		} `json:"addressMatches"`
+	} `json:"result"`
+}
+
+type GeoNamesResponse struct {
+	Geonames []struct {
+		Lat         string  `json:"lat"`
+		Lng         string  `json:"lng"`
+		Name        string  `json:"name"`
+		AdminName1  string  `json:"adminName1"`
+		CountryName string  `json:"countryName"`
+		CountryCode string  `json:"countryCode"`
+		Score       float64 `json:"score"`
+	} `json:"geonames"`
+	Status *struct {
+		Message string `json:"message"`
+		Value   int    `json:"value"`
+	} `json:"status"`
+}
+
+type GeocodioResponse struct {
+	Results []struct {
+		FormattedAddress string `json:"formatted_address"`
+		Location         struct {
+			Lat *float64 `json:"lat"`
+			Lng *float64 `json:"lng"`
+		} `json:"location"`
+		Accuracy          float64        `json:"accuracy"`
+		AddressComponents map[string]any `json:"address_components"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+// attributions holds the notice each provider's terms ask for. Providers
+// that send their own in the response override it.
+var attributions = map[string]string{
+	"google":        "Map data © Google",
+	"positionstack": "© positionstack",
+	"opencage":      "© OpenCage, © OpenStreetMap contributors",
+	"locationiq":    "© LocationIQ, © OpenStreetMap contributors",
+	"mapquest":      "© MapQuest",
+	"mapbox":        "© Mapbox, © OpenStreetMap contributors",
+	"here":          "© HERE",
+	"tomtom":        "© TomTom",
+	"bing":          "© Microsoft",
+	"yandex":        "© Yandex",
+	"geonames":      "GeoNames, CC BY 4.0",
+	"geocodio":      "Geocodio",
+	"osm":           "© OpenStreetMap contributors, ODbL 1.0",
+	"photon":        "© OpenStreetMap contributors, ODbL 1.0",
+	"census":        "U.S. Census Bureau",
+	"pelias":        "© OpenStreetMap contributors, © Geocode Earth",
+}
+
+// mapQuestQuality maps MapQuest's geocodeQuality granularity onto a 0-1
+// confidence score; finer granularity scores higher.
+var mapQuestQuality = map[string]float64{
+	"POINT":        1.0,
+	"ADDRESS":      0.9,
+	"INTERSECTION": 0.8,
+	"STREET":       0.7,
+	"NEIGHBORHOOD": 0.5,
+	"ZIP":          0.4,
+	"CITY":         0.3,
+	"COUNTY":       0.2,
+	"STATE":        0.1,
+	"COUNTRY":      0.05,
+}
+
+// bingConfidence maps Bing's High/Medium/Low match confidence onto a 0-1
+// score.
+var bingConfidence = map[string]float64{
+	"High":   0.9,
+	"Medium": 0.6,
+	"Low":    0.3,
+}
+
+// yandexPrecision maps Yandex's match precision onto a 0-1 confidence score.
+var yandexPrecision = map[string]float64{
+	"exact":  1.0,
+	"number": 0.9,
+	"near":   0.8,
+	"range":  0.7,
+	"street": 0.6,
+	"other":  0.3,
+}
+
+// ----------- Provider functions -----------
+
+func geocodeGoogle(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return nil, missingKey("GOOGLE_API_KEY")
+	}
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/geocode/json")
+	query := fmt.Sprintf("%s?address=%s&key=%s", endpoint, url.QueryEscape(address), apiKey)
+	query += c.biasParams("google")
+	query += c.langParams("google")
+	return googleSearch(ctx, c, address, query, limit)
+}
+
+// googleSearch runs a prepared Google geocoding query.
+func googleSearch(ctx context.Context, c *client, address, query string, limit int) ([]GeocodeResult, error) {
+	var result GoogleGeocodeResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Status != "OK" {
+		return nil, googleStatusError(result.Status)
+	}
+	if len(result.Results) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.Results {
+		lat, lng, err := requireCoords(r.Geometry.Location.Lat, r.Geometry.Location.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("google", address, lat, lng)
+		res.MatchType = googleMatchType(r.Geometry.LocationType, r.Types)
+		res.Formatted = r.FormattedAddress
+		for _, comp := range r.AddressComponents {
+			if len(comp.Types) > 0 {
+				res.setComponent(comp.Types[0], comp.LongName)
+			}
+			if slices.Contains(comp.Types, "country") {
+				res.setComponent("country_code", comp.ShortName)
+			}
+		}
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeOSM(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	endpoint := c.endpoint("osm", "https://nominatim.openstreetmap.org", "/search")
+	query := fmt.Sprintf("%s?q=%s&format=json&addressdetails=1&limit=%d", endpoint, url.QueryEscape(address), limit)
+	query += c.biasParams("osm")
+	query += c.langParams("osm")
+	return osmSearch(ctx, c, address, query, limit)
+}
+
+// osmSearch runs a prepared Nominatim search query.
+func osmSearch(ctx context.Context, c *client, address, query string, limit int) ([]GeocodeResult, error) {
+	var result OSMGeocodeResponse
+	if err := c.getJSON(ctx, query, c.osmHeader(), &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result {
+		lat, lng, err := parseCoords(r.Lat, r.Lon)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("osm", address, lat, lng)
+		if r.Licence != "" {
+			res.Attribution = r.Licence
+		}
+		res.Confidence = clamp01(r.Importance)
+		res.MatchType = osmMatchType(r.Class, r.Type)
+		res.Formatted = r.DisplayName
+		for k, v := range r.Address {
+			res.setComponent(k, v)
+		}
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+// positionstack's free plan is served over plain HTTP only, so that is the
+// default; paid plans also serve HTTPS, which Options.RequireHTTPS selects.
+const (
+	positionstackHTTP  = "http://api.positionstack.com"
+	positionstackHTTPS = "https://api.positionstack.com"
+)
+
+func geocodePositionstack(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("positionstack", "POSITIONSTACK_KEY")
+	if apiKey == "" {
+		return nil, missingKey("POSITIONSTACK_KEY")
+	}
+	base := positionstackHTTP
+	if c.opts.RequireHTTPS {
+		base = positionstackHTTPS
+	}
+	endpoint := c.endpoint("positionstack", base, "/v1/forward")
+	query := fmt.Sprintf("%s?access_key=%s&query=%s&limit=%d", endpoint, apiKey, url.QueryEscape(address), limit)
+	query += c.biasParams("positionstack")
+	query += c.langParams("positionstack")
+	var result PositionstackResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, d := range result.Data {
+		lat, lng, err := requireCoords(d.Latitude, d.Longitude)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("positionstack", address, lat, lng)
+		res.Formatted = d.Label
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeOpenCage(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("opencage", "OPENCAGE_KEY")
+	if apiKey == "" {
+		return nil, missingKey("OPENCAGE_KEY")
+	}
+	endpoint := c.endpoint("opencage", "https://api.opencagedata.com", "/geocode/v1/json")
+	query := fmt.Sprintf("%s?q=%s&key=%s&limit=%d", endpoint, url.QueryEscape(address), apiKey, limit)
+	query += c.biasParams("opencage")
+	query += c.langParams("opencage")
+	var result OpenCageResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.Results {
+		lat, lng, err := requireCoords(r.Geometry.Lat, r.Geometry.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("opencage", address, lat, lng)
+		if len(result.Licenses) > 0 {
+			l := result.Licenses[0]
+			res.Attribution = strings.Join(nonEmpty(l.Name, l.URL), ", ")
+		}
+		res.Confidence = clamp01(float64(r.Confidence) / 10)
+		typ, _ := r.Components["_type"].(string)
+		res.MatchType = openCageMatchType(typ)
+		res.Formatted = r.Formatted
+		setComponents(&res, r.Components)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeLocationIQ(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("locationiq", "LOCATIONIQ_KEY")
+	if apiKey == "" {
+		return nil, missingKey("LOCATIONIQ_KEY")
+	}
+	endpoint := c.endpoint("locationiq", "https://us1.locationiq.com", "/v1/search.php")
+	query := fmt.Sprintf("%s?key=%s&q=%s&format=json&addressdetails=1&limit=%d", endpoint, apiKey, url.QueryEscape(address), limit)
+	query += c.biasParams("locationiq")
+	query += c.langParams("locationiq")
+	var result LocationIQResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	return locationIQResults(address, result, limit)
+}
+
+// locationIQResults converts a LocationIQ search or autocomplete response.
+func locationIQResults(address string, result LocationIQResponse, limit int) ([]GeocodeResult, error) {
+	if len(result) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result {
+		lat, lng, err := parseCoords(r.Lat, r.Lon)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("locationiq", address, lat, lng)
+		if r.Licence != "" {
+			res.Attribution = r.Licence
+		}
+		res.Confidence = clamp01(r.Importance)
+		res.MatchType = osmMatchType(r.Class, r.Type)
+		res.Formatted = r.DisplayName
+		for k, v := range r.Address {
+			res.setComponent(k, v)
+		}
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeMapQuest(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("mapquest", "MAPQUEST_KEY")
+	if apiKey == "" {
+		return nil, missingKey("MAPQUEST_KEY")
+	}
+	endpoint := c.endpoint("mapquest", "https://www.mapquestapi.com", "/geocoding/v1/address")
+	query := fmt.Sprintf("%s?key=%s&location=%s&maxResults=%d", endpoint, apiKey, url.QueryEscape(address), limit)
+	query += c.biasParams("mapquest")
+	query += c.langParams("mapquest")
+	var result MapQuestResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Info.Statuscode != 0 {
+		return nil, mapQuestStatusError(result.Info.Statuscode, result.Info.Messages)
+	}
+	if len(result.Results) == 0 || len(result.Results[0].Locations) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.Results {
+		for _, loc := range r.Locations {
+			lat, lng, err := requireCoords(loc.LatLng.Lat, loc.LatLng.Lng)
+			if err != nil {
+				return nil, err
+			}
+			res := newResult("mapquest", address, lat, lng)
+			res.Confidence = mapQuestQuality[loc.GeocodeQuality]
+			res.MatchType = mapQuestMatchType(loc.GeocodeQualityCode)
+			results = append(results, res)
+		}
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeMapbox(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	token := c.key("mapbox", "MAPBOX_TOKEN")
+	if token == "" {
+		return nil, missingKey("MAPBOX_TOKEN")
+	}
+	endpoint := c.endpoint("mapbox", "https://api.mapbox.com", "/geocoding/v5/mapbox.places")
+	query := fmt.Sprintf("%s/%s.json?access_token=%s&limit=%d", endpoint, url.PathEscape(address), token, limit)
+	query += c.biasParams("mapbox")
+	query += c.langParams("mapbox")
+	var result MapboxResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Features) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, f := range result.Features {
+		if err := requirePair(f.Center); err != nil {
+			return nil, err
+		}
+		// Mapbox returns the center as [lng, lat].
+		lat, lng, err := requireCoords(f.Center[1], f.Center[0])
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("mapbox", address, lat, lng)
+		if result.Attribution != "" {
+			res.Attribution = result.Attribution
+		}
+		res.Confidence = clamp01(f.Relevance)
+		res.Formatted = f.PlaceName
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeHere(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("here", "HERE_API_KEY")
+	if apiKey == "" {
+		return nil, missingKey("HERE_API_KEY")
+	}
+	endpoint := c.endpoint("here", "https://geocode.search.hereapi.com", "/v1/geocode")
+	query := fmt.Sprintf("%s?q=%s&apiKey=%s&limit=%d", endpoint, url.QueryEscape(address), apiKey, limit)
+	query += c.biasParams("here")
+	query += c.langParams("here")
+	var result HereResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, item := range result.Items {
+		lat, lng, err := requireCoords(item.Position.Lat, item.Position.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("here", address, lat, lng)
+		res.Confidence = clamp01(item.Scoring.QueryScore)
+		if label, ok := item.Address["label"].(string); ok {
+			res.Formatted = label
+		}
+		setComponents(&res, item.Address)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeTomTom(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("tomtom", "TOMTOM_KEY")
+	if apiKey == "" {
+		return nil, missingKey("TOMTOM_KEY")
+	}
+	// The address is embedded in the path, so use PathEscape: QueryEscape
+	// encodes spaces as '+', which a path treats as a literal plus sign.
+	endpoint := c.endpoint("tomtom", "https://api.tomtom.com", "/search/2/geocode")
+	query := fmt.Sprintf("%s/%s.json?key=%s&limit=%d", endpoint, url.PathEscape(address), apiKey, limit)
+	query += c.biasParams("tomtom")
+	query += c.langParams("tomtom")
+	var result TomTomResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.Results {
+		lat, lng, err := requireCoords(r.Position.Lat, r.Position.Lon)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("tomtom", address, lat, lng)
+		if freeform, ok := r.Address["freeformAddress"].(string); ok {
+			res.Formatted = freeform
+		}
+		setComponents(&res, r.Address)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeBing(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("bing", "BING_MAPS_KEY")
+	if apiKey == "" {
+		return nil, missingKey("BING_MAPS_KEY")
+	}
+	endpoint := c.endpoint("bing", "https://dev.virtualearth.net", "/REST/v1/Locations")
+	query := fmt.Sprintf("%s?query=%s&key=%s&maxResults=%d", endpoint, url.QueryEscape(address), apiKey, limit)
+	query += c.biasParams("bing")
+	query += c.langParams("bing")
+	var result BingResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.ResourceSets) == 0 || len(result.ResourceSets[0].Resources) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.ResourceSets[0].Resources {
+		if err := requirePair(r.Point.Coordinates); err != nil {
+			return nil, err
+		}
+		lat, lng, err := requireCoords(r.Point.Coordinates[0], r.Point.Coordinates[1])
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("bing", address, lat, lng)
+		if result.Copyright != "" {
+			res.Attribution = result.Copyright
+		}
+		res.Confidence = bingConfidence[r.Confidence]
+		if formatted, ok := r.Address["formattedAddress"].(string); ok {
+			res.Formatted = formatted
+		}
+		setComponents(&res, r.Address)
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return limitResults(results, limit), nil
+}
+
+func geocodeYandex(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("yandex", "YANDEX_KEY")
+	if apiKey == "" {
+		return nil, missingKey("YANDEX_KEY")
+	}
+	endpoint := c.endpoint("yandex", "https://geocode-maps.yandex.ru", "/1.x/")
+	query := fmt.Sprintf("%s?apikey=%s&geocode=%s&format=json&results=%d", endpoint, apiKey, url.QueryEscape(address), limit)
+	query += c.biasParams("yandex")
+	query += c.langParams("yandex")
+	var result YandexResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	var results []GeocodeResult
+	for _, m := range result.Response.GeoObjectCollection.FeatureMember {
+		obj := m.GeoObject
+		lat, lng, err := parseYandexPos(obj.Point.Pos)
+		if err != nil {
+			return nil, err
+		}
+		meta := obj.MetaDataProperty.GeocoderMetaData
+		res := newResult("yandex", address, lat, lng)
+		res.Confidence = yandexPrecision[meta.Precision]
+		res.Formatted = meta.Text
+		for _, comp := range meta.Address.Components {
+			res.setComponent(comp.Kind, comp.Name)
+		}
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return limitResults(results, limit), nil
+}
+
+// parseYandexPos parses Yandex's "lng lat" point string. Note the order:
+// longitude comes first.
+func parseYandexPos(pos string) (lat, lng float64, err error) {
+	parts := strings.Fields(pos)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: invalid position %q", ErrProvider, pos)
+	}
+	if lng, err = strconv.ParseFloat(parts[0], 64); err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid position %q", ErrProvider, pos)
+	}
+	if lat, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid position %q", ErrProvider, pos)
+	}
+	return finiteCoords(lat, lng)
+}
+
+// defaultPhotonURL is komoot's public Photon instance, used unless
+// PHOTON_URL points at a self-hosted one.
+const defaultPhotonURL = "https://photon.komoot.io/api/"
+
+func geocodePhoton(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	endpoint := searchURLOverride("photon")
+	if endpoint == "" {
+		endpoint = defaultPhotonURL
+	}
+	query := fmt.Sprintf("%s?q=%s&limit=%d", endpoint, url.QueryEscape(address), limit)
+	query += c.biasParams("photon")
+	query += c.langParams("photon")
+	var result PhotonResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	var results []GeocodeResult
+	for _, f := range result.Features {
+		if err := requirePair(f.Geometry.Coordinates); err != nil {
+			return nil, err
+		}
+		lat, lng, err := requireCoords(f.Geometry.Coordinates[1], f.Geometry.Coordinates[0])
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("photon", address, lat, lng)
+		res.Formatted = photonLabel(f.Properties)
+		setComponents(&res, f.Properties)
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return limitResults(results, limit), nil
+}
+
+// photonLabel builds a display address from Photon's properties, which do
+// not include a preformatted one.
+func photonLabel(props map[string]any) string {
+	var parts []string
+	add := func(s string) {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	str := func(key string) string {
+		s, _ := props[key].(string)
+		return s
+	}
+	add(str("name"))
+	add(strings.TrimSpace(str("street") + " " + str("housenumber")))
+	add(strings.TrimSpace(str("postcode") + " " + str("city")))
+	add(str("country"))
+	return strings.Join(parts, ", ")
+}
+
+// geocodeCensus queries the US Census Bureau geocoder, which only covers US
+// addresses and ignores bounds and language.
+func geocodeCensus(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	if country := strings.ToLower(c.opts.Country); country != "" && country != "us" {
+		return nil, fmt.Errorf("%w: census only covers the US", ErrNoResults)
+	}
+	endpoint := c.endpoint("census", "https://geocoding.geo.census.gov", "/geocoder/locations/onelineaddress")
+	query := fmt.Sprintf("%s?address=%s&benchmark=Public_AR_Current&format=json", endpoint, url.QueryEscape(address))
+	var result CensusResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Result.AddressMatches) == 0 {
+		return nil, fmt.Errorf("%w: no US match", ErrNoResults)
+	}
+	var results []GeocodeResult
+	for _, m := range result.Result.AddressMatches {
+		lat, lng, err := requireCoords(m.Coordinates.Y, m.Coordinates.X)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("census", address, lat, lng)
+		res.Formatted = m.MatchedAddress
+		setComponents(&res, m.AddressComponents)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+// defaultPeliasURL is the hosted Geocode Earth search endpoint, used unless
+// PELIAS_URL points at a self-hosted Pelias instance.
+const defaultPeliasURL = "https://api.geocode.earth/v1/search"
+
+// geocodePelias queries a Pelias search endpoint. Self-hosted instances
+// usually need no key, so the key is optional: it is sent as api_key only
+// when PELIAS_API_KEY (or Options.Keys["pelias"]) is set.
+func geocodePelias(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	endpoint := searchURLOverride("pelias")
+	if endpoint == "" {
+		endpoint = defaultPeliasURL
+	}
+	query := fmt.Sprintf("%s?text=%s&size=%d", endpoint, url.QueryEscape(address), limit)
+	if apiKey := c.key("pelias", optionalKeyEnvs["pelias"]); apiKey != "" {
+		query += "&api_key=" + url.QueryEscape(apiKey)
+	}
+	query += c.biasParams("pelias")
+	query += c.langParams("pelias")
+	var result PeliasResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	var results []GeocodeResult
+	for _, f := range result.Features {
+		if err := requirePair(f.Geometry.Coordinates); err != nil {
+			return nil, err
+		}
+		lat, lng, err := requireCoords(f.Geometry.Coordinates[1], f.Geometry.Coordinates[0])
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("pelias", address, lat, lng)
+		if confidence, ok := f.Properties["confidence"].(float64); ok {
+			res.Confidence = clamp01(confidence)
+		}
+		if label, ok := f.Properties["label"].(string); ok {
+			res.Formatted = label
+		}
+		setComponents(&res, f.Properties)
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+	return limitResults(results, limit), nil
+}
+
+// geocodeGeoNames searches GeoNames place names. GeoNames authenticates with
+// an account username rather than an API key.
+func geocodeGeoNames(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	username := c.key("geonames", "GEONAMES_USERNAME")
+	if username == "" {
+		return nil, missingKey("GEONAMES_USERNAME")
+	}
+	endpoint := c.endpoint("geonames", "https://secure.geonames.org", "/searchJSON")
+	query := fmt.Sprintf("%s?q=%s&maxRows=%d&username=%s", endpoint, url.QueryEscape(address), limit, url.QueryEscape(username))
+	query += c.biasParams("geonames")
+	query += c.langParams("geonames")
+	var result GeoNamesResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Status != nil {
+		return nil, geoNamesStatusError(result.Status.Value, result.Status.Message)
+	}
+	if len(result.Geonames) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, g := range result.Geonames {
+		lat, lng, err := parseCoords(g.Lat, g.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("geonames", address, lat, lng)
+		res.Formatted = strings.Join(nonEmpty(g.Name, g.AdminName1, g.CountryName), ", ")
+		res.setComponent("name", g.Name)
+		res.setComponent("adminName1", g.AdminName1)
+		res.setComponent("countryName", g.CountryName)
+		res.setComponent("countryCode", g.CountryCode)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+// geocodeGeocodio queries Geocodio, which covers the US and Canada only and
+// takes no bias or language parameters.
+func geocodeGeocodio(ctx context.Context, c *client, address string, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("geocodio", "GEOCODIO_KEY")
+	if apiKey == "" {
+		return nil, missingKey("GEOCODIO_KEY")
+	}
+	endpoint := c.endpoint("geocodio", "https://api.geocod.io", "/v1.7/geocode")
+	query := fmt.Sprintf("%s?q=%s&api_key=%s&limit=%d", endpoint, url.QueryEscape(address), apiKey, limit)
+	var result GeocodioResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrBadRequest, result.Error)
+	}
+	if len(result.Results) == 0 {
+		return nil, ErrNoResults
+	}
+	var results []GeocodeResult
+	for _, r := range result.Results {
+		lat, lng, err := requireCoords(r.Location.Lat, r.Location.Lng)
+		if err != nil {
+			return nil, err
+		}
+		res := newResult("geocodio", address, lat, lng)
+		res.Confidence = clamp01(r.Accuracy)
+		res.Formatted = r.FormattedAddress
+		setComponents(&res, r.AddressComponents)
+		results = append(results, res)
+	}
+	return limitResults(results, limit), nil
+}
+
+// nonEmpty returns the non-empty strings among ss.
+func nonEmpty(ss ...string) []string {
+	var out []string
+	for _, s := range ss {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ----------- Reverse provider functions -----------
+
+func reverseGoogle(ctx context.Context, c *client, lat, lng float64) (string, error) {
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return "", missingKey("GOOGLE_API_KEY")
+	}
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/geocode/json")
+	query := fmt.Sprintf("%s?latlng=%f,%f&key=%s", endpoint, lat, lng, apiKey)
+	query += c.langParams("google")
+	var result GoogleGeocodeResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return "", err
+	}
+	if result.Status != "OK" {
+		return "", googleStatusError(result.Status)
+	}
+	if len(result.Results) == 0 {
+		return "", ErrNoResults
+	}
+	return result.Results[0].FormattedAddress, nil
+}
+
+func reverseOSM(ctx context.Context, c *client, lat, lng float64) (string, error) {
+	endpoint := c.endpoint("osm", "https://nominatim.openstreetmap.org", "/reverse")
+	query := fmt.Sprintf("%s?lat=%f&lon=%f&format=json", endpoint, lat, lng)
+	var result OSMReverseResponse
+	if err := c.getJSON(ctx, query, c.osmHeader(), &result); err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("%w (%s)", ErrNoResults, result.Error)
+	}
+	if result.DisplayName == "" {
+		return "", ErrNoResults
+	}
+	return result.DisplayName, nil
+}
+
+func reverseOpenCage(ctx context.Context, c *client, lat, lng float64) (string, error) {
+	apiKey := c.key("opencage", "OPENCAGE_KEY")
+	if apiKey == "" {
+		return "", missingKey("OPENCAGE_KEY")
+	}
+	endpoint := c.endpoint("opencage", "https://api.opencagedata.com", "/geocode/v1/json")
+	query := fmt.Sprintf("%s?q=%s&key=%s&limit=1", endpoint, url.QueryEscape(fmt.Sprintf("%f,%f", lat, lng)), apiKey)
+	query += c.langParams("opencage")
+	var result OpenCageResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 {
+		return "", ErrNoResults
+	}
+	return result.Results[0].Formatted, nil
+}
@@ -0,0 +1,63 @@
+package geocode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// componentAliases maps the generic component names accepted by
+// Options.Require to the keys providers use for them. Any other name is
+// matched against the component key as given.
+var componentAliases = map[string][]string{
+	"country":  {"country", "country_code", "countryCode", "countryRegion", "CountryCode"},
+	"postcode": {"postcode", "postal_code", "postalCode", "zip", "ZIP", "PostalCode"},
+	"city":     {"city", "locality", "town", "village", "municipality", "LocalityName"},
+	"state":    {"state", "state_code", "stateCode", "administrative_area_level_1", "adminName1", "adminDistrict", "region"},
+}
+
+// matchesComponents reports whether r has, for every required component,
+// some matching key whose value equals the required one, ignoring case.
+func matchesComponents(r GeocodeResult, require map[string]string) bool {
+	for name, want := range require {
+		keys, ok := componentAliases[strings.ToLower(name)]
+		if !ok {
+			keys = []string{name}
+		}
+		found := false
+		for _, k := range keys {
+			if strings.EqualFold(strings.TrimSpace(r.Components[k]), want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// filterRequired drops results whose components do not satisfy
+// Options.Require, failing with ErrNoResults when none remain so the next
+// provider is tried.
+func (c *client) filterRequired(results []GeocodeResult) ([]GeocodeResult, error) {
+	if len(c.opts.Require) == 0 {
+		return results, nil
+	}
+	var kept []GeocodeResult
+	for _, r := range results {
+		if matchesComponents(r, c.opts.Require) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		var want []string
+		for name, value := range c.opts.Require {
+			want = append(want, name+"="+value)
+		}
+		sort.Strings(want)
+		return nil, fmt.Errorf("%w matching %s", ErrNoResults, strings.Join(want, ", "))
+	}
+	return kept, nil
+}
@@ -0,0 +1,94 @@
+package geocode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Criteria accepted by SelectAnswer.
+const (
+	SelectConfidence = "confidence" // highest provider confidence
+	SelectAccuracy   = "accuracy"   // finest MatchType, then confidence
+	SelectAgreement  = "agreement"  // closest to the largest agreeing cluster
+)
+
+// Selection is the single answer SelectAnswer picked from several
+// providers, with the reason it won.
+type Selection struct {
+	Result    GeocodeResult    `json:"result"`
+	By        string           `json:"selected_by"`
+	Reason    string           `json:"reason"`
+	Providers []ProviderAnswer `json:"providers"`
+}
+
+// matchRank orders match types from coarsest to finest.
+var matchRank = map[string]int{
+	MatchUnknown:      1,
+	MatchLocality:     2,
+	MatchStreet:       3,
+	MatchInterpolated: 4,
+	MatchRooftop:      5,
+}
+
+// SelectAnswer picks the best successful answer by the given criterion.
+// For SelectAgreement, answers within maxDistance meters of each other form
+// clusters (see ClusterAnswers); the answer closest to the centroid of the
+// largest cluster wins, so outliers cannot. Ties go to the earlier answer.
+// It reports false when no provider succeeded.
+func SelectAnswer(answers []ProviderAnswer, by string, maxDistance float64) (Selection, bool) {
+	sel := Selection{By: by, Providers: answers}
+	var ok []ProviderAnswer
+	for _, a := range answers {
+		if a.Result != nil {
+			ok = append(ok, a)
+		}
+	}
+	if len(ok) == 0 {
+		return sel, false
+	}
+
+	best := ok[0]
+	switch by {
+	case SelectConfidence:
+		for _, a := range ok[1:] {
+			if a.Result.Confidence > best.Result.Confidence {
+				best = a
+			}
+		}
+		sel.Reason = fmt.Sprintf("highest confidence (%.2f) of %d results", best.Result.Confidence, len(ok))
+	case SelectAccuracy:
+		for _, a := range ok[1:] {
+			ra, rb := matchRank[a.Result.MatchType], matchRank[best.Result.MatchType]
+			if ra > rb || ra == rb && a.Result.Confidence > best.Result.Confidence {
+				best = a
+			}
+		}
+		match := best.Result.MatchType
+		if match == "" {
+			match = "not reported"
+		}
+		sel.Reason = fmt.Sprintf("most precise match (%s) of %d results", match, len(ok))
+	case SelectAgreement:
+		cl := ClusterAnswers(ok, maxDistance)[0]
+		in := make(map[string]bool)
+		for _, name := range cl.Providers {
+			in[name] = true
+		}
+		dist := -1.0
+		for _, a := range ok {
+			if !in[a.Provider] {
+				continue
+			}
+			d := Haversine(cl.Latitude, cl.Longitude, a.Result.Latitude, a.Result.Longitude)
+			if dist < 0 || d < dist {
+				best, dist = a, d
+			}
+		}
+		sel.Reason = fmt.Sprintf("closest (%.0f m) to the centroid of the %d of %d results that agree (%s)",
+			dist, len(cl.Providers), len(ok), strings.Join(cl.Providers, ", "))
+	default:
+		sel.Reason = "first success"
+	}
+	sel.Result = *best.Result
+	return sel, true
+}
@@ -0,0 +1,77 @@
+package geocode
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/url"
+)
+
+// NewSessionToken returns a random version 4 UUID for Options.SessionToken.
+// Use a new token for every search: a session ends once GeocodePlace
+// resolves one of its suggestions.
+func NewSessionToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type GooglePlaceResponse struct {
+	Result struct {
+		FormattedAddress string `json:"formatted_address"`
+		Geometry         struct {
+			Location struct {
+				Lat *float64 `json:"lat"`
+				Lng *float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	} `json:"result"`
+	Status string `json:"status"`
+}
+
+// GeocodePlace resolves a Google place ID, as found on the PlaceID of a
+// Google autocomplete suggestion, with a Place Details request. With
+// opts.SessionToken set to the token the suggestions were made under, the
+// autocomplete requests and this one are billed as a single session.
+func GeocodePlace(ctx context.Context, placeID string, opts Options) (GeocodeResult, error) {
+	c := newClient(opts)
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return GeocodeResult{}, missingKey("GOOGLE_API_KEY")
+	}
+	ctx, cancel := c.attempt(ctx)
+	defer cancel()
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/place/details/json")
+	query := fmt.Sprintf("%s?place_id=%s&fields=formatted_address,geometry&key=%s", endpoint, url.QueryEscape(placeID), apiKey)
+	query += c.sessionParams()
+	query += c.langParams("google")
+	var result GooglePlaceResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return GeocodeResult{}, err
+	}
+	if result.Status != "OK" {
+		return GeocodeResult{}, googleStatusError(result.Status)
+	}
+	loc := result.Result.Geometry.Location
+	lat, lng, err := requireCoords(loc.Lat, loc.Lng)
+	if err != nil {
+		return GeocodeResult{}, err
+	}
+	res := newResult("google", placeID, lat, lng)
+	res.Formatted = result.Result.FormattedAddress
+	res.PlaceID = placeID
+	return res, nil
+}
+
+// sessionParams returns the sessiontoken parameter for Google Places
+// requests, or "" outside a session.
+func (c *client) sessionParams() string {
+	if c.opts.SessionToken == "" {
+		return ""
+	}
+	return "&sessiontoken=" + url.QueryEscape(c.opts.SessionToken)
+}
@@ -0,0 +1,108 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StructuredAddress is an address given as separate fields rather than one
+// free-form string. Empty fields are omitted.
+type StructuredAddress struct {
+	Street     string
+	City       string
+	PostalCode string
+	Country    string // name or ISO code
+}
+
+// String joins the fields into a single free-form address, as sent to
+// providers without structured query support.
+func (a StructuredAddress) String() string {
+	var parts []string
+	for _, f := range []string{a.Street, a.City, a.PostalCode, a.Country} {
+		if f = strings.TrimSpace(f); f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// structuredSearchers holds the providers that accept separate address
+// fields. Every other provider receives StructuredAddress.String().
+var structuredSearchers = map[string]func(context.Context, *client, StructuredAddress, int) ([]GeocodeResult, error){
+	"google": geocodeGoogleStructured,
+	"osm":    geocodeOSMStructured,
+}
+
+// SupportsStructured reports whether the provider takes separate address
+// fields, rather than the joined StructuredAddress.String().
+func (p Provider) SupportsStructured() bool {
+	return structuredSearchers[p.Name] != nil
+}
+
+// GeocodeStructured resolves a structured address to up to opts.Limit
+// matches from the first provider that succeeds. Each result's Address is
+// addr.String().
+func GeocodeStructured(ctx context.Context, addr StructuredAddress, opts Options) ([]GeocodeResult, error) {
+	c := newClient(opts)
+	return c.run(ctx, lookupRequest{address: NormalizeAddress(addr.String(), false), structured: &addr})
+}
+
+// geocodeGoogleStructured sends the street as the address and the other
+// fields as components filters.
+func geocodeGoogleStructured(ctx context.Context, c *client, addr StructuredAddress, limit int) ([]GeocodeResult, error) {
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return nil, missingKey("GOOGLE_API_KEY")
+	}
+	country := addr.Country
+	if country == "" {
+		country = c.opts.Country
+	}
+	var components []string
+	for _, f := range []struct{ name, value string }{
+		{"locality", addr.City},
+		{"postal_code", addr.PostalCode},
+		{"country", country},
+	} {
+		if f.value != "" {
+			components = append(components, f.name+":"+f.value)
+		}
+	}
+
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/geocode/json")
+	query := fmt.Sprintf("%s?key=%s", endpoint, apiKey)
+	if addr.Street != "" {
+		query += "&address=" + url.QueryEscape(addr.Street)
+	}
+	if len(components) > 0 {
+		query += "&components=" + url.QueryEscape(strings.Join(components, "|"))
+	}
+	// The country filter is already part of components above.
+	nc := *c
+	nc.opts.Country = ""
+	query += nc.biasParams("google")
+	query += c.langParams("google")
+	return googleSearch(ctx, c, addr.String(), query, limit)
+}
+
+// geocodeOSMStructured uses Nominatim's structured search parameters, which
+// cannot be combined with q.
+func geocodeOSMStructured(ctx context.Context, c *client, addr StructuredAddress, limit int) ([]GeocodeResult, error) {
+	endpoint := c.endpoint("osm", "https://nominatim.openstreetmap.org", "/search")
+	query := fmt.Sprintf("%s?format=json&addressdetails=1&limit=%d", endpoint, limit)
+	for _, f := range []struct{ name, value string }{
+		{"street", addr.Street},
+		{"city", addr.City},
+		{"postalcode", addr.PostalCode},
+		{"country", addr.Country},
+	} {
+		if f.value != "" {
+			query += "&" + f.name + "=" + url.QueryEscape(f.value)
+		}
+	}
+	query += c.biasParams("osm")
+	query += c.langParams("osm")
+	return osmSearch(ctx, c, addr.String(), query, limit)
+}
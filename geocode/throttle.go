@@ -0,0 +1,48 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultOSMInterval is the minimum spacing between Nominatim requests
+// required by its usage policy (at most one request per second).
+const DefaultOSMInterval = time.Second
+
+// osmHost is the host whose requests go through osmLimiter.
+const osmHost = "nominatim.openstreetmap.org"
+
+// osmLimiter is shared by every lookup in the process, so concurrent batch
+// workers are serialized on Nominatim no matter how many of them run.
+var osmLimiter = rate.NewLimiter(rate.Every(DefaultOSMInterval), 1)
+
+// globalLimiter enforces Options.QPS across every provider and goroutine.
+// Nominatim requests pass through both limiters, so the stricter one wins.
+var globalLimiter = rate.NewLimiter(rate.Inf, 1)
+
+// errThrottled means a request was never sent because our own rate limit
+// had no slot for it before the attempt's deadline. It wraps
+// context.DeadlineExceeded, so it is classed as a timeout.
+var errThrottled = errors.New("no rate limit slot before the deadline")
+
+// waitLimiter blocks until l allows a request, spacing requests at least
+// interval apart, or until ctx ends. It fails at once with errThrottled if
+// ctx's deadline comes before the slot. The limit follows the caller's
+// options, so lookups with different settings share one limiter at the
+// latest setting.
+func waitLimiter(ctx context.Context, l *rate.Limiter, interval time.Duration) error {
+	if limit := rate.Every(interval); l.Limit() != limit {
+		l.SetLimit(limit)
+	}
+	if err := l.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %w", errThrottled, context.DeadlineExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type GoogleTimeZoneResponse struct {
+	TimeZoneID string  `json:"timeZoneId"`
+	RawOffset  float64 `json:"rawOffset"`
+	DSTOffset  float64 `json:"dstOffset"`
+	Status     string  `json:"status"`
+}
+
+// TimeZone returns the IANA time zone ID at a coordinate and its current
+// offset from UTC, including daylight saving time, using the Google Time
+// Zone API.
+func TimeZone(ctx context.Context, lat, lng float64, opts Options) (string, time.Duration, error) {
+	c := newClient(opts)
+	apiKey := c.key("google", "GOOGLE_API_KEY")
+	if apiKey == "" {
+		return "", 0, fmt.Errorf("time zone: %w", missingKey("GOOGLE_API_KEY"))
+	}
+	ctx, cancel := c.attempt(ctx)
+	defer cancel()
+	endpoint := c.endpoint("google", "https://maps.googleapis.com", "/maps/api/timezone/json")
+	query := fmt.Sprintf("%s?location=%f,%f&timestamp=%d&key=%s", endpoint, lat, lng, time.Now().Unix(), apiKey)
+	var result GoogleTimeZoneResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return "", 0, fmt.Errorf("time zone: %w", err)
+	}
+	if result.Status != "OK" {
+		return "", 0, fmt.Errorf("time zone: %w", googleStatusError(result.Status))
+	}
+	offset := time.Duration(result.RawOffset+result.DSTOffset) * time.Second
+	return result.TimeZoneID, offset, nil
+}
@@ -0,0 +1,85 @@
+package geocode
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTiming records the phases of one HTTP request through httptrace,
+// so the debug log can tell a slow DNS lookup or handshake from a slow
+// server. Phases that did not happen, e.g. on a reused connection, stay
+// zero.
+type requestTiming struct {
+	mu         sync.Mutex
+	start      time.Time
+	dnsStart   time.Time
+	dnsDone    time.Time
+	connStart  time.Time
+	connDone   time.Time
+	tlsStart   time.Time
+	tlsDone    time.Time
+	firstByte  time.Time
+	reusedConn bool
+}
+
+func newRequestTiming() *requestTiming {
+	return &requestTiming{start: time.Now()}
+}
+
+// mark sets *field to now under the lock, keeping the first value unless
+// last is set.
+func (t *requestTiming) mark(field *time.Time, last bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() || last {
+		*field = time.Now()
+	}
+}
+
+// trace returns the hooks that fill in t. Dialing may race several
+// addresses, so connection start keeps the first attempt and done the last
+// successful one.
+func (t *requestTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart, false) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone, true) },
+		ConnectStart: func(string, string) { t.mark(&t.connStart, false) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.mark(&t.connDone, true)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart, false) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.mark(&t.tlsDone, true)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reusedConn = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte, false) },
+	}
+}
+
+// attrs returns the phase durations as slog attributes, ending with the
+// total time so far.
+func (t *requestTiming) attrs() []any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	attrs := []any{"reused", t.reusedConn}
+	phase := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			attrs = append(attrs, name, to.Sub(from))
+		}
+	}
+	phase("dns", t.dnsStart, t.dnsDone)
+	phase("connect", t.connStart, t.connDone)
+	phase("tls", t.tlsStart, t.tlsDone)
+	phase("ttfb", t.start, t.firstByte)
+	return append(attrs, "total", time.Since(t.start))
+}
@@ -0,0 +1,39 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+)
+
+type What3WordsResponse struct {
+	Words string `json:"words"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// What3Words returns the what3words address ("filled.count.soap") of a
+// coordinate, using the convert-to-3wa API with the key in W3W_KEY.
+func What3Words(ctx context.Context, lat, lng float64, opts Options) (string, error) {
+	c := newClient(opts)
+	apiKey := c.key("w3w", "W3W_KEY")
+	if apiKey == "" {
+		return "", fmt.Errorf("what3words: %w", missingKey("W3W_KEY"))
+	}
+	ctx, cancel := c.attempt(ctx)
+	defer cancel()
+	endpoint := c.endpoint("w3w", "https://api.what3words.com", "/v3/convert-to-3wa")
+	query := fmt.Sprintf("%s?coordinates=%f,%f&key=%s", endpoint, lat, lng, apiKey)
+	var result What3WordsResponse
+	if err := c.getJSON(ctx, query, nil, &result); err != nil {
+		return "", fmt.Errorf("what3words: %w", err)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("what3words: %w: %s: %s", ErrProvider, result.Error.Code, result.Error.Message)
+	}
+	if result.Words == "" {
+		return "", fmt.Errorf("what3words: %w", ErrNoResults)
+	}
+	return result.Words, nil
+}
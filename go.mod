@@ -0,0 +1,5 @@
+module github.com/fasoulas/geolooker
+
+go 1.22
+
+require golang.org/x/time v0.5.0
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Health check -----------
+
+// healthCheckAddress is geocoded against every provider by --healthcheck.
+const healthCheckAddress = "1600 Amphitheatre Parkway"
+
+// healthStatus is the outcome of checking one provider.
+type healthStatus struct {
+	name    string
+	skipped bool
+	latency time.Duration
+	err     error
+}
+
+// healthCheck geocodes healthCheckAddress against each provider in opts
+// concurrently, bypassing the cache, and writes one OK/FAIL/SKIP line per
+// provider to w. Keyed providers without a key are skipped. It reports
+// whether every provider that was checked succeeded.
+func healthCheck(ctx context.Context, w io.Writer, opts geocode.Options) bool {
+	opts.Cache = nil
+	opts.Race = false
+	opts.Limit = 1
+
+	statuses := make([]healthStatus, len(opts.Providers))
+	var wg sync.WaitGroup
+	for i, name := range opts.Providers {
+		statuses[i].name = name
+		p, _ := geocode.LookupProvider(name)
+		if p.NeedsKey() && opts.Key(p) == "" {
+			statuses[i].skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(st *healthStatus) {
+			defer wg.Done()
+			popts := opts
+			popts.Providers = []string{st.name}
+			popts.OnFailure = func(_ string, err error) { st.err = err }
+			start := time.Now()
+			_, err := geocode.GeocodeMany(ctx, healthCheckAddress, popts)
+			st.latency = time.Since(start)
+			if err != nil && st.err == nil {
+				st.err = err
+			}
+		}(&statuses[i])
+	}
+	wg.Wait()
+
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, st := range statuses {
+		switch {
+		case st.skipped:
+			fmt.Fprintf(tw, "%s\tSKIP\t\tno API key\n", st.name)
+		case st.err != nil:
+			ok = false
+			fmt.Fprintf(tw, "%s\tFAIL\t%s\t%v\n", st.name, st.latency.Round(time.Millisecond), st.err)
+		default:
+			fmt.Fprintf(tw, "%s\tOK\t%s\t\n", st.name, st.latency.Round(time.Millisecond))
+		}
+	}
+	tw.Flush()
+	return ok
+}
@@ -0,0 +1,60 @@
+package main
+
+import "github.com/fasoulas/geolooker/geocode"
+
+// ----------- Provider list -----------
+
+// providerList is the --list-providers output.
+type providerList struct {
+	Providers []providerInfo `json:"providers"`
+	// Enrichments lists the per-result lookups behind flags such as --w3w,
+	// which need keys of their own.
+	Enrichments []enrichmentInfo `json:"enrichments"`
+}
+
+// providerInfo describes one provider for --list-providers. EnvVar is also
+// set when the key is optional, with RequiresKey false.
+type providerInfo struct {
+	Name                 string `json:"name"`
+	RequiresKey          bool   `json:"requiresKey"`
+	EnvVar               string `json:"envVar,omitempty"`
+	SupportsReverse      bool   `json:"supportsReverse"`
+	SupportsAutocomplete bool   `json:"supportsAutocomplete"`
+	SupportsStructured   bool   `json:"supportsStructured"`
+}
+
+// enrichmentInfo describes the key used by one enrichment flag.
+type enrichmentInfo struct {
+	Flag        string `json:"flag"`
+	RequiresKey bool   `json:"requiresKey"`
+	EnvVar      string `json:"envVar"`
+}
+
+// enrichments matches the flags' help: --elevation only prefers Google and
+// falls back to the keyless Open-Elevation.
+var enrichments = []enrichmentInfo{
+	{"elevation", false, "GOOGLE_API_KEY"},
+	{"timezone", true, "GOOGLE_API_KEY"},
+	{"w3w", true, "W3W_KEY"},
+}
+
+// providerInfos describes every registered provider in the default order,
+// followed by the enrichment flags.
+func providerInfos() providerList {
+	list := providerList{Enrichments: enrichments}
+	for _, p := range geocode.Providers() {
+		env := p.KeyEnv
+		if env == "" {
+			env = p.OptionalKeyEnv()
+		}
+		list.Providers = append(list.Providers, providerInfo{
+			Name:                 p.Name,
+			RequiresKey:          p.NeedsKey(),
+			EnvVar:               env,
+			SupportsReverse:      p.SupportsReverse(),
+			SupportsAutocomplete: p.SupportsAutocomplete(),
+			SupportsStructured:   p.SupportsStructured(),
+		})
+	}
+	return list
+}
@@ -1,320 +1,1176 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand"
 	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fasoulas/geolooker/geocode"
 )
 
-// ----------- Response structs -----------
-
-type GoogleGeocodeResponse struct {
-	Results []struct {
-		Geometry struct {
-			Location struct {
-				Lat float64 `json:"lat"`
-				Lng float64 `json:"lng"`
-			} `json:"location"`
-		} `json:"geometry"`
-	} `json:"results"`
-	Status string `json:"status"`
+// ----------- Helper functions -----------
+
+// quiet suppresses warnings and per-provider failure messages (--quiet).
+var quiet bool
+
+// warnf prints a warning to stderr unless --quiet is set.
+func warnf(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	}
 }
 
-type OSMGeocodeResponse []struct {
-	Lat string `json:"lat"`
-	Lon string `json:"lon"`
+// parseLatLng parses a "lat,lng" pair as given on the command line. Both
+// values must be finite and in range; an out-of-range latitude that would
+// be valid the other way round gets a hint that the pair looks swapped.
+func parseLatLng(s string) (float64, float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q, expected lat,lng", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || math.IsNaN(lat) || math.IsInf(lat, 0) {
+		return 0, 0, fmt.Errorf("invalid latitude %q", parts[0])
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		return 0, 0, fmt.Errorf("invalid longitude %q", parts[1])
+	}
+	if lat < -90 || lat > 90 {
+		if lng >= -90 && lng <= 90 && lat >= -180 && lat <= 180 {
+			return 0, 0, fmt.Errorf("latitude %g out of range [-90,90]; did you swap them? Expected lat,lng, e.g. %g,%g", lat, lng, lat)
+		}
+		return 0, 0, fmt.Errorf("latitude %g out of range [-90,90]", lat)
+	}
+	if lng < -180 || lng > 180 {
+		return 0, 0, fmt.Errorf("longitude %g out of range [-180,180]", lng)
+	}
+	return lat, lng, nil
 }
 
-type PositionstackResponse struct {
-	Data []struct {
-		Latitude  float64 `json:"latitude"`
-		Longitude float64 `json:"longitude"`
-	} `json:"data"`
+// parseBounds parses a "minLng,minLat,maxLng,maxLat" bounding box.
+func parseBounds(s string) (*geocode.Bounds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid bounds %q, expected minLng,minLat,maxLng,maxLat", s)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bounds %q: %q is not a number", s, part)
+		}
+		v[i] = f
+	}
+	b := &geocode.Bounds{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	if b.MinLng >= b.MaxLng || b.MinLat >= b.MaxLat {
+		return nil, fmt.Errorf("invalid bounds %q: minimum must be below maximum", s)
+	}
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLng < -180 || b.MaxLng > 180 {
+		return nil, fmt.Errorf("bounds out of range: %s", s)
+	}
+	return b, nil
 }
 
-type OpenCageResponse struct {
-	Results []struct {
-		Geometry struct {
-			Lat float64 `json:"lat"`
-			Lng float64 `json:"lng"`
-		} `json:"geometry"`
-	} `json:"results"`
+// preferProvider returns order with name moved (or added) to the front.
+func preferProvider(order []string, name string) []string {
+	out := []string{name}
+	for _, n := range order {
+		if n != name {
+			out = append(out, n)
+		}
+	}
+	return out
 }
 
-type LocationIQResponse []struct {
-	Lat string `json:"lat"`
-	Lon string `json:"lon"`
+// addElevation sets the elevation of each result. A failed elevation lookup
+// is reported but leaves the result otherwise intact.
+func addElevation(ctx context.Context, results []geocode.GeocodeResult, opts geocode.Options) {
+	for i := range results {
+		r := &results[i]
+		if r.Unresolved {
+			continue
+		}
+		e, err := geocode.Elevation(ctx, r.Latitude, r.Longitude, opts)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		r.Elevation = &e
+	}
 }
 
-type MapQuestResponse struct {
-	Results []struct {
-		Locations []struct {
-			LatLng struct {
-				Lat float64 `json:"lat"`
-				Lng float64 `json:"lng"`
-			} `json:"latLng"`
-		} `json:"locations"`
-	} `json:"results"`
-	Info struct {
-		Statuscode int `json:"statuscode"`
-	} `json:"info"`
+// addTimeZone sets the time zone of each result. Like addElevation, a
+// failure is reported without dropping the result.
+func addTimeZone(ctx context.Context, results []geocode.GeocodeResult, opts geocode.Options) {
+	for i := range results {
+		r := &results[i]
+		if r.Unresolved {
+			continue
+		}
+		id, offset, err := geocode.TimeZone(ctx, r.Latitude, r.Longitude, opts)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		r.TimeZoneID = id
+		r.UTCOffset = formatUTCOffset(offset)
+	}
 }
 
-// ----------- Output struct -----------
+// addWords sets the what3words address of each result. It fails soft like
+// addElevation: without W3W_KEY, or when the call fails, Words stays empty.
+func addWords(ctx context.Context, results []geocode.GeocodeResult, opts geocode.Options) {
+	for i := range results {
+		r := &results[i]
+		if r.Unresolved {
+			continue
+		}
+		words, err := geocode.What3Words(ctx, r.Latitude, r.Longitude, opts)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		r.Words = words
+	}
+}
 
-type GeocodeResult struct {
-	Provider  string  `json:"provider"`
-	Address   string  `json:"address"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+// formatUTCOffset formats an offset as ±hh:mm.
+func formatUTCOffset(d time.Duration) string {
+	sign := '+'
+	if d < 0 {
+		sign = '-'
+		d = -d
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, int(d.Hours()), int(d.Minutes())%60)
 }
 
-// ----------- Helper functions -----------
+// componentFilters collects repeated --require name=value flags.
+type componentFilters map[string]string
 
-func printJSON(provider, address string, lat, lng float64) {
-	res := GeocodeResult{
-		Provider:  provider,
-		Address:   address,
-		Latitude:  lat,
-		Longitude: lng,
+func (f componentFilters) String() string {
+	var parts []string
+	for name, value := range f {
+		parts = append(parts, name+"="+value)
 	}
-	data, _ := json.MarshalIndent(res, "", "  ")
-	fmt.Println(string(data))
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
 }
 
-func parseFloat(s string) float64 {
-	f, _ := strconv.ParseFloat(s, 64)
-	return f
+func (f componentFilters) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || value == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	f[name] = value
+	return nil
 }
 
-// ----------- Provider functions -----------
+// providersEnv names the environment variable holding the default provider
+// order, in the same comma-separated form as --providers.
+const providersEnv = "GEOLOOKER_PROVIDERS"
+
+// envPrefix prefixes the environment variables that supply flag defaults,
+// e.g. GEOLOOKER_FORMAT for --format and GEOLOOKER_USER_AGENT for
+// --user-agent.
+const envPrefix = "GEOLOOKER_"
+
+// envFlags are the flags that take a default from GEOLOOKER_<NAME>. Only
+// options that tune a lookup or its output are listed: flags that pick a
+// mode or action (--serve, --input, --version, ...), name the query itself,
+// or weaken security (--insecure) must be given explicitly, so a stray
+// variable cannot change what the command does. --providers is left out
+// too: $GEOLOOKER_PROVIDERS is the default provider order, not a forced
+// list.
+var envFlags = []string{
+	"provider", "config", "limit", "country", "region", "feature-type", "bounds", "lang",
+	"timeout", "timeout-total", "retries", "max-retry-after", "seed", "osm-rate", "qps",
+	"breaker-threshold", "breaker-window", "breaker-cooldown",
+	"cache", "cache-size", "cache-ttl", "user-agent", "proxy", "require-https", "env-file",
+	"quiet", "verbose", "raw", "normalize", "reject-null-island", "require", "min-confidence",
+	"no-key-warning-fatal", "fail-fast", "fallback-on", "race", "cluster-dist", "min-quorum", "quorum-warn",
+	"format", "output-fields", "coords", "geohash", "pluscode", "elevation", "timezone", "w3w", "round",
+	"address-column", "concurrency",
+}
 
-func geocodeGoogle(address string) (float64, float64, error) {
-	apiKey := os.Getenv("GOOGLE_API_KEY")
-	if apiKey == "" {
-		return 0, 0, fmt.Errorf("GOOGLE_API_KEY not set")
+// applyEnvDefaults sets each of envFlags that has a GEOLOOKER_<NAME>
+// variable to its value. It runs before flag.Parse, so the precedence is
+// command line, then environment, then the built-in default.
+func applyEnvDefaults() error {
+	for _, name := range envFlags {
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if v, ok := os.LookupEnv(env); ok {
+			if err := flag.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %v", v, env, err)
+			}
+		}
 	}
-	endpoint := "https://maps.googleapis.com/maps/api/geocode/json"
-	resp, err := http.Get(fmt.Sprintf("%s?address=%s&key=%s", endpoint, url.QueryEscape(address), apiKey))
-	if err != nil {
-		return 0, 0, err
+	return nil
+}
+
+// parseProviderNames parses a comma-separated list of provider names.
+// Unknown or repeated names are an error; source names the flag or variable
+// the list came from.
+func parseProviderNames(source, s string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := geocode.LookupProvider(name); !ok {
+			return nil, fmt.Errorf("%s: unknown provider %q", source, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s: provider %q listed more than once", source, name)
+		}
+		seen[name] = true
+		names = append(names, name)
 	}
-	defer resp.Body.Close()
+	return names, nil
+}
+
+// exitMissingKey reports that keyed provider p has no key and exits, for
+// --no-key-warning-fatal.
+func exitMissingKey(p geocode.Provider) {
+	fmt.Fprintf(os.Stderr, "Error: API key for provider '%s' not set via --%s-key or environment variable %s\n", p.Name, p.Name, p.KeyEnv)
+	os.Exit(1)
+}
 
-	var result GoogleGeocodeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+// parseProviderList parses a --providers value into an ordered list of
+// provider names. Keyed providers without a key are dropped with a warning.
+func parseProviderList(s string, keys map[string]string) ([]string, error) {
+	all, err := parseProviderNames("--providers", s)
+	if err != nil {
+		return nil, err
 	}
-	if result.Status != "OK" || len(result.Results) == 0 {
-		return 0, 0, fmt.Errorf("no results (status: %s)", result.Status)
+	var names []string
+	for _, name := range all {
+		p, _ := geocode.LookupProvider(name)
+		if p.NeedsKey() && (geocode.Options{Keys: keys}).Key(p) == "" {
+			warnf("API key for provider '%s' not set via --%s-key or environment variable %s. Skipping it.", p.Name, p.Name, p.KeyEnv)
+			continue
+		}
+		names = append(names, name)
 	}
-	lat := result.Results[0].Geometry.Location.Lat
-	lng := result.Results[0].Geometry.Location.Lng
-	return lat, lng, nil
+	if len(names) == 0 {
+		return nil, fmt.Errorf("--providers: no usable providers")
+	}
+	return names, nil
 }
 
-func geocodeOSM(address string) (float64, float64, error) {
-	endpoint := "https://nominatim.openstreetmap.org/search"
-	query := fmt.Sprintf("%s?q=%s&format=json&limit=1", endpoint, url.QueryEscape(address))
-	req, _ := http.NewRequest("GET", query, nil)
-	req.Header.Set("User-Agent", "Go-Geocoder/1.0")
+// ----------- Main function -----------
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, 0, err
+// reportFailure prints a provider error to stderr, calling out timeouts so
+// they are not mistaken for an empty result set. A zero timeout means the
+// deadline may have come from --timeout-total, so no duration is printed.
+func reportFailure(name string, err error, timeout time.Duration) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && timeout == 0:
+		fmt.Fprintf(os.Stderr, "Provider %s timed out\n", name)
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintf(os.Stderr, "Provider %s timed out after %s\n", name, timeout)
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(os.Stderr, "Provider %s cancelled\n", name)
+	case errors.Is(err, geocode.ErrCircuitOpen):
+		fmt.Fprintf(os.Stderr, "Provider %s circuit open, skipping\n", name)
+	case errors.Is(err, geocode.ErrAuth):
+		fmt.Fprintf(os.Stderr, "Error: provider %s rejected the API key, check it is valid: %v\n", name, err)
+	default:
+		fmt.Fprintf(os.Stderr, "Provider %s failed: %v\n", name, err)
 	}
-	defer resp.Body.Close()
+}
+
+func main() {
+	autocomplete := flag.Bool("autocomplete", false, "Suggest matches for partial input instead of geocoding it (default --limit 5)")
+	showVersion := flag.Bool("version", false, "Print version and build information, then exit")
+	listProviders := flag.Bool("list-providers", false, "Print the supported providers and enrichment flags, their key variables and capabilities as JSON, then exit")
+	provider := flag.String("provider", "osm", "Provider to try first; the others follow the default order (--config or $"+providersEnv+")")
+	providerList := flag.String("providers", "", "Comma-separated providers to try, in order, e.g. google,osm (overrides --provider, --config and $"+providersEnv+")")
+	reverse := flag.Bool("reverse", false, "Reverse geocode: treat the argument as lat,lng and look up an address")
+	limit := flag.Int("limit", 1, "Maximum number of results to return per query")
+	configPath := flag.String("config", "", "Path to a JSON config file defining the default provider order (overrides $"+providersEnv+") and --consensus weights")
+	country := flag.String("country", "", "Restrict results to an ISO 3166-1 alpha-2 country code")
+	featureType := flag.String("feature-type", "", "Restrict matches to a kind of place on osm and photon: "+strings.Join(geocode.FeatureTypes, ", "))
+	region := flag.String("region", "", "Softly bias Google results towards a ccTLD region, e.g. uk (unlike --country, does not exclude others)")
+	var structured geocode.StructuredAddress
+	flag.StringVar(&structured.Street, "street", "", "Structured query: street and house number")
+	flag.StringVar(&structured.City, "city", "", "Structured query: city")
+	flag.StringVar(&structured.PostalCode, "postalcode", "", "Structured query: postal code")
+	flag.StringVar(&structured.Country, "country-name", "", "Structured query: country name or code")
+	boundsFlag := flag.String("bounds", "", "Bias results to a bounding box: minLng,minLat,maxLng,maxLat")
+	lang := flag.String("lang", "", "Preferred language for results, e.g. en or de (default: provider's default)")
+	timeout := flag.Duration("timeout", 10*time.Second, "Per-provider request timeout")
+	timeoutTotal := flag.Duration("timeout-total", 0, "Deadline for a whole lookup across all providers (0 = none)")
+	retries := flag.Int("retries", 2, "Retries per provider on 429, 5xx or network errors")
+	maxRetryAfter := flag.Duration("max-retry-after", 0, "Longest wait honored from a provider's Retry-After header before retrying (0 = as asked, within --timeout)")
+	seed := flag.Int64("seed", 0, "Seed for retry backoff jitter, for reproducible runs (0 = random)")
+	osmRate := flag.Float64("osm-rate", 1, "Maximum Nominatim (osm) requests per second, shared by all workers")
+	qps := flag.Float64("qps", 0, "Maximum requests per second across all providers (0 = unlimited)")
+	breakerThreshold := flag.Int("breaker-threshold", 5, "Consecutive failures within --breaker-window after which a provider is skipped for --breaker-cooldown (0 disables)")
+	breakerWindow := flag.Duration("breaker-window", geocode.DefaultBreakerWindow, "Time span within which --breaker-threshold failures open a provider's circuit")
+	breakerCooldown := flag.Duration("breaker-cooldown", geocode.DefaultBreakerCooldown, "How long a provider with an open circuit is skipped before a trial request")
+	cachePath := flag.String("cache", "", "Cache results in this JSON file")
+	cacheSize := flag.Int("cache-size", 1000, "Entries in the in-memory result cache used by --serve (0 disables it)")
+	cacheTTL := flag.Duration("cache-ttl", 30*24*time.Hour, "How long cached results stay valid (0 = forever)")
+	userAgent := flag.String("user-agent", geocode.DefaultUserAgent, "User-Agent sent to every provider; include contact details for Nominatim")
+	proxy := flag.String("proxy", "", "HTTP proxy URL for provider requests (default: HTTP_PROXY/HTTPS_PROXY); NO_PROXY hosts bypass it")
+	requireHTTPS := flag.Bool("require-https", false, "Skip providers whose endpoint is plain http:// (e.g. an *_ENDPOINT override) instead of sending keys in cleartext; positionstack switches to HTTPS, which its free plan lacks")
+	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification (debugging only)")
+	flag.BoolVar(&quiet, "quiet", false, "Suppress warnings and per-provider failure messages")
+	flag.BoolVar(&quiet, "q", false, "Shorthand for --quiet")
+	verbose := flag.Bool("verbose", false, "Log each provider request and response to stderr, with DNS, connect, TLS and time-to-first-byte timings")
+	raw := flag.Bool("raw", false, "Include each provider's raw JSON response (credentials redacted) in JSON output")
+	flag.BoolVar(verbose, "v", false, "Shorthand for --verbose")
+	normalize := flag.Bool("normalize", false, "Expand the street-type abbreviation (St, Ave, Rd, ...) before the first comma before querying")
+	rejectNullIsland := flag.Bool("reject-null-island", false, "Treat a 0,0 result as a provider failure")
+	require := componentFilters{}
+	flag.Var(require, "require", "Only accept results whose address component matches, e.g. country=US or postcode=90210; repeatable")
+	minConfidence := flag.Float64("min-confidence", 0, "Treat matches below this confidence (0-1) as failures and try the next provider")
+	keyWarningFatal := flag.Bool("no-key-warning-fatal", false, "Exit with an error, instead of warning and falling back, when the selected provider (--provider, or the first of --providers) has no API key")
+	failFast := flag.Bool("fail-fast", false, "Report the first provider's error and exit instead of falling back to the others")
+	fallbackOn := flag.String("fallback-on", "", "Comma-separated failure classes that fall back to the next provider ("+strings.Join(geocode.FailureClasses, ", ")+"); others are final. Default: all")
+	race := flag.Bool("race", false, "Query all providers concurrently and return the fastest success")
+	compareFlag := flag.String("compare", "", "Compare exactly two providers on the address, e.g. google,osm")
+	consensusMode := flag.Bool("consensus", false, "Query all providers and report the median coordinate")
+	clusterDist := flag.Float64("cluster-dist", 100, "Distance in meters within which --consensus and --select-by agreement answers count as agreeing")
+	selectBy := flag.String("select-by", "", "Query all providers and keep the best answer by confidence, accuracy or agreement (default: first success)")
+	minQuorum := flag.Int("min-quorum", 0, "Minimum number of providers that must succeed for --consensus to be trusted (0 = any)")
+	quorumWarn := flag.Bool("quorum-warn", false, "Only warn, instead of exiting nonzero, when --min-quorum is not met")
+	format := flag.String("format", "json", "Output format: json, jsonl, csv, geojson, kml or table")
+	outputFieldsFlag := flag.String("output-fields", "", "Comma-separated fields to keep in json, jsonl or csv output, in order, e.g. provider,latitude,longitude")
+	coords := flag.String("coords", "decimal", "Coordinate notation to add to the output: decimal, dms or utm")
+	geohash := &optionalInt{def: 9}
+	flag.Var(geohash, "geohash", "Add a geohash to each result; optionally --geohash=N for N characters (default 9)")
+	pluscode := flag.Bool("pluscode", false, "Add an Open Location Code (plus code) to each result")
+	serveAddr := flag.String("serve", "", "Run an HTTP server on this address (e.g. :8080) instead of a one-off lookup")
+	input := flag.String("input", "", "Geocode each line of this file instead of the command-line argument")
+	inputCSV := flag.String("input-csv", "", "Geocode the --address-column of each row of this CSV file, appending coordinates")
+	addressColumn := flag.String("address-column", "address", "Column holding the address in --input-csv")
+	concurrency := flag.Int("concurrency", 1, "Number of addresses to geocode in parallel in batch mode")
+	envFile := flag.String("env-file", "", "Load API keys from this .env file (default: .env in the working directory, if present)")
+	healthcheckMode := flag.Bool("healthcheck", false, "Geocode a known address against every provider and report which work")
+	elevation := flag.Bool("elevation", false, "Add the ground elevation in meters to each result")
+	timezone := flag.Bool("timezone", false, "Add the local time zone and UTC offset to each result (needs a Google key)")
+	w3w := flag.Bool("w3w", false, "Add the what3words address to each result (needs W3W_KEY)")
+	round := flag.Int("round", 6, "Decimal places for output coordinates (6 is about 11 cm; -1 for full precision)")
+	output := flag.String("output", "", "Write results to this file instead of stdout")
+	replMode := flag.Bool("repl", false, "Read addresses interactively from stdin, one per line, until EOF or \"quit\"")
+	session := flag.Bool("session", false, "With --repl --autocomplete, bill Google suggestions as one Places session per search: they come without coordinates; enter a suggestion's number to geocode it")
+	explainMode := flag.Bool("explain", false, "Print the providers that would be tried, and why others are skipped, then exit")
 
-	var result OSMGeocodeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+	// One --<provider>-key flag per keyed provider, e.g. --google-key.
+	keyFlags := make(map[string]*string)
+	for _, p := range geocode.Providers() {
+		if p.NeedsKey() {
+			keyFlags[p.Name] = flag.String(p.Name+"-key", "", fmt.Sprintf("API key for %s (overrides %s)", p.Name, p.KeyEnv))
+		}
 	}
-	if len(result) == 0 {
-		return 0, 0, fmt.Errorf("no results")
+	if err := applyEnvDefaults(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
 	}
-	lat, lng := parseFloat(result[0].Lat), parseFloat(result[0].Lon)
-	return lat, lng, nil
-}
+	flag.Parse()
 
-func geocodePositionstack(address string) (float64, float64, error) {
-	apiKey := os.Getenv("POSITIONSTACK_KEY")
-	if apiKey == "" {
-		return 0, 0, fmt.Errorf("POSITIONSTACK_KEY not set")
+	if *showVersion {
+		printVersion(os.Stdout)
+		return
 	}
-	endpoint := "http://api.positionstack.com/v1/forward"
-	query := fmt.Sprintf("%s?access_key=%s&query=%s&limit=1", endpoint, apiKey, url.QueryEscape(address))
-	resp, err := http.Get(query)
-	if err != nil {
-		return 0, 0, err
+	if *listProviders {
+		if err := writeIndented(os.Stdout, providerInfos()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer resp.Body.Close()
 
-	var result PositionstackResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+	// Key variables from a .env file must be in place before any key check.
+	// The default file is optional; an explicit --env-file must exist.
+	if *envFile != "" {
+		if err := loadDotEnv(*envFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if err := loadDotEnv(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	if len(result.Data) == 0 {
-		return 0, 0, fmt.Errorf("no results")
+
+	keys := make(map[string]string)
+	for name, v := range keyFlags {
+		if *v != "" {
+			keys[name] = *v
+		}
 	}
-	return result.Data[0].Latitude, result.Data[0].Longitude, nil
-}
 
-func geocodeOpenCage(address string) (float64, float64, error) {
-	apiKey := os.Getenv("OPENCAGE_KEY")
-	if apiKey == "" {
-		return 0, 0, fmt.Errorf("OPENCAGE_KEY not set")
+	providerSet, limitSet := false, false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "provider":
+			providerSet = true
+		case "limit":
+			limitSet = true
+		}
+	})
+
+	// Suggestions are only useful as a list.
+	if *autocomplete && !limitSet {
+		*limit = 5
 	}
-	endpoint := "https://api.opencagedata.com/geocode/v1/json"
-	query := fmt.Sprintf("%s?q=%s&key=%s&limit=1", endpoint, url.QueryEscape(address), apiKey)
-	resp, err := http.Get(query)
-	if err != nil {
-		return 0, 0, err
+
+	if *retries < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --retries must not be negative")
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	var result OpenCageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+	if *qps < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --qps must not be negative")
+		os.Exit(1)
 	}
-	if len(result.Results) == 0 {
-		return 0, 0, fmt.Errorf("no results")
+
+	if *osmRate <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --osm-rate must be positive")
+		os.Exit(1)
 	}
-	lat := result.Results[0].Geometry.Lat
-	lng := result.Results[0].Geometry.Lng
-	return lat, lng, nil
-}
 
-func geocodeLocationIQ(address string) (float64, float64, error) {
-	apiKey := os.Getenv("LOCATIONIQ_KEY")
-	if apiKey == "" {
-		return 0, 0, fmt.Errorf("LOCATIONIQ_KEY not set")
+	if *country != "" && len(*country) != 2 {
+		fmt.Fprintf(os.Stderr, "Error: --country must be a two-letter ISO code, got %q\n", *country)
+		os.Exit(1)
 	}
-	endpoint := "https://us1.locationiq.com/v1/search.php"
-	query := fmt.Sprintf("%s?key=%s&q=%s&format=json&limit=1", endpoint, apiKey, url.QueryEscape(address))
-	resp, err := http.Get(query)
-	if err != nil {
-		return 0, 0, err
+
+	var bounds *geocode.Bounds
+	if *boundsFlag != "" {
+		var err error
+		bounds, err = parseBounds(*boundsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	defer resp.Body.Close()
 
-	var result LocationIQResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+	if *limit < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --limit must be at least 1")
+		os.Exit(1)
 	}
-	if len(result) == 0 {
-		return 0, 0, fmt.Errorf("no results")
+
+	if *minConfidence < 0 || *minConfidence > 1 {
+		fmt.Fprintln(os.Stderr, "Error: --min-confidence must be between 0 and 1")
+		os.Exit(1)
 	}
-	lat, lng := parseFloat(result[0].Lat), parseFloat(result[0].Lon)
-	return lat, lng, nil
-}
 
-func geocodeMapQuest(address string) (float64, float64, error) {
-	apiKey := os.Getenv("MAPQUEST_KEY")
-	if apiKey == "" {
-		return 0, 0, fmt.Errorf("MAPQUEST_KEY not set")
+	if *failFast && (*race || *consensusMode) {
+		fmt.Fprintln(os.Stderr, "Error: --fail-fast cannot be combined with --race or --consensus")
+		os.Exit(1)
 	}
-	endpoint := "http://www.mapquestapi.com/geocoding/v1/address"
-	query := fmt.Sprintf("%s?key=%s&location=%s", endpoint, apiKey, url.QueryEscape(address))
-	resp, err := http.Get(query)
-	if err != nil {
-		return 0, 0, err
+
+	var fallbackClasses []string
+	if *fallbackOn != "" {
+		if *failFast || *race || *consensusMode {
+			fmt.Fprintln(os.Stderr, "Error: --fallback-on cannot be combined with --fail-fast, --race or --consensus")
+			os.Exit(1)
+		}
+		for _, class := range strings.Split(*fallbackOn, ",") {
+			class = strings.TrimSpace(class)
+			if !slices.Contains(geocode.FailureClasses, class) {
+				fmt.Fprintf(os.Stderr, "Error: --fallback-on: unknown failure class %q (want %s)\n", class, strings.Join(geocode.FailureClasses, ", "))
+				os.Exit(1)
+			}
+			fallbackClasses = append(fallbackClasses, class)
+		}
 	}
-	defer resp.Body.Close()
 
-	var result MapQuestResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, 0, err
+	useStructured := structured != geocode.StructuredAddress{}
+	if useStructured && (flag.NArg() > 0 || *reverse || *consensusMode || *autocomplete || *input != "") {
+		fmt.Fprintln(os.Stderr, "Error: structured fields (--street, --city, --postalcode, --country-name) replace the address argument and cannot be combined with --reverse, --consensus, --autocomplete or --input")
+		os.Exit(1)
 	}
-	if result.Info.Statuscode != 0 || len(result.Results) == 0 || len(result.Results[0].Locations) == 0 {
-		return 0, 0, fmt.Errorf("no results")
+
+	switch *selectBy {
+	case "", geocode.SelectConfidence, geocode.SelectAccuracy, geocode.SelectAgreement:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --select-by %q (want confidence, accuracy or agreement)\n", *selectBy)
+		os.Exit(1)
+	}
+	if *selectBy != "" && (*consensusMode || *compareFlag != "" || *reverse || *input != "" || *inputCSV != "" || *autocomplete || *race || *format != "json") {
+		fmt.Fprintln(os.Stderr, "Error: --select-by only supports JSON output and cannot be combined with --consensus, --compare, --reverse, --input, --input-csv, --autocomplete or --race")
+		os.Exit(1)
 	}
-	lat := result.Results[0].Locations[0].LatLng.Lat
-	lng := result.Results[0].Locations[0].LatLng.Lng
-	return lat, lng, nil
-}
 
-// ----------- Main function -----------
+	if *featureType != "" && !slices.Contains(geocode.FeatureTypes, *featureType) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --feature-type %q (want %s)\n", *featureType, strings.Join(geocode.FeatureTypes, ", "))
+		os.Exit(1)
+	}
 
-func main() {
-	provider := flag.String("provider", "osm", "Primary geocoding provider")
-	flag.Parse()
+	var compareNames []string
+	if *compareFlag != "" {
+		var err error
+		compareNames, err = parseCompare(*compareFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *consensusMode || *reverse || *input != "" || *autocomplete || *format != "json" {
+			fmt.Fprintln(os.Stderr, "Error: --compare only supports JSON output and cannot be combined with --consensus, --reverse, --input or --autocomplete")
+			os.Exit(1)
+		}
+	}
+
+	if *autocomplete && (*reverse || *consensusMode) {
+		fmt.Fprintln(os.Stderr, "Error: --autocomplete cannot be combined with --reverse or --consensus")
+		os.Exit(1)
+	}
+
+	if *session && (!*replMode || !*autocomplete) {
+		fmt.Fprintln(os.Stderr, "Error: --session requires --repl and --autocomplete")
+		os.Exit(1)
+	}
+	if *session && *format != "json" && *format != "jsonl" {
+		// Session suggestions have no coordinates to put in a table, CSV
+		// row or map feature.
+		fmt.Fprintln(os.Stderr, "Error: --session only supports JSON or JSON lines output")
+		os.Exit(1)
+	}
+
+	if *consensusMode && (*reverse || *race) {
+		fmt.Fprintln(os.Stderr, "Error: --consensus cannot be combined with --reverse or --race")
+		os.Exit(1)
+	}
+
+	switch *format {
+	case "json", "jsonl", "csv", "geojson", "kml", "table":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q\n", *format)
+		os.Exit(1)
+	}
+
+	if *clusterDist <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --cluster-dist must be positive")
+		os.Exit(1)
+	}
+
+	if *consensusMode && *format != "json" && *format != "table" {
+		fmt.Fprintln(os.Stderr, "Error: --consensus only supports JSON or table output")
+		os.Exit(1)
+	}
+
+	var fields []string
+	if *outputFieldsFlag != "" {
+		if *format != "json" && *format != "jsonl" && *format != "csv" {
+			fmt.Fprintln(os.Stderr, "Error: --output-fields only supports json, jsonl or csv output")
+			os.Exit(1)
+		}
+		if *consensusMode || *compareFlag != "" || *selectBy != "" || *inputCSV != "" || *serveAddr != "" || *healthcheckMode {
+			fmt.Fprintln(os.Stderr, "Error: --output-fields cannot be combined with --consensus, --compare, --select-by, --input-csv, --serve or --healthcheck")
+			os.Exit(1)
+		}
+		var err error
+		if fields, err = parseOutputFields(*outputFieldsFlag, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *coords != "decimal" && *coords != "dms" && *coords != "utm" {
+		fmt.Fprintf(os.Stderr, "Error: unknown coordinate format %q\n", *coords)
+		os.Exit(1)
+	}
+	if geohash.value > 12 {
+		fmt.Fprintln(os.Stderr, "Error: --geohash precision must be between 1 and 12")
+		os.Exit(1)
+	}
+	annotate := annotations{coords: *coords, geohash: geohash.value, pluscode: *pluscode}
+
+	if *cacheSize < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --cache-size must not be negative")
+		os.Exit(1)
+	}
+
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --concurrency must be at least 1")
+		os.Exit(1)
+	}
+
+	if *breakerThreshold < 0 || *breakerWindow <= 0 || *breakerCooldown <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --breaker-threshold must not be negative and --breaker-window and --breaker-cooldown must be positive")
+		os.Exit(1)
+	}
+
+	if *inputCSV != "" && (*input != "" || *consensusMode || *reverse || *compareFlag != "") {
+		fmt.Fprintln(os.Stderr, "Error: --input-csv cannot be combined with --input, --consensus, --reverse or --compare")
+		os.Exit(1)
+	}
+
+	if *input != "" && *consensusMode {
+		fmt.Fprintln(os.Stderr, "Error: --input cannot be combined with --consensus")
+		os.Exit(1)
+	}
+
+	if *output != "" && *serveAddr != "" {
+		fmt.Fprintln(os.Stderr, "Error: --output cannot be combined with --serve")
+		os.Exit(1)
+	}
+
+	// "distance <from> <to>" measures between two addresses instead of
+	// geocoding a single one.
+	distanceMode := flag.NArg() > 1 && flag.Arg(0) == "distance"
+	if distanceMode {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "Error: distance takes exactly two addresses")
+			os.Exit(1)
+		}
+		if *reverse || *input != "" || *consensusMode || *serveAddr != "" || fields != nil {
+			fmt.Fprintln(os.Stderr, "Error: distance cannot be combined with --reverse, --input, --consensus, --serve or --output-fields")
+			os.Exit(1)
+		}
+		if *format != "json" {
+			fmt.Fprintln(os.Stderr, "Error: distance only supports JSON output")
+			os.Exit(1)
+		}
+	}
 
-	if flag.NArg() < 1 {
-		fmt.Println("Usage: geocode --provider <provider> <address>")
+	if flag.NArg() < 1 && *input == "" && *inputCSV == "" && *serveAddr == "" && !*explainMode && !*healthcheckMode && !*replMode && !useStructured {
+		fmt.Println("Usage: geocode [--reverse] --provider <provider> <address | lat,lng>")
+		fmt.Println("       geocode [--street <street>] [--city <city>] [--postalcode <code>] [--country-name <country>]")
+		fmt.Println("       geocode [--reverse] --input <file>")
+		fmt.Println("       geocode --input-csv <file.csv> --address-column <name>")
+		fmt.Println("       geocode distance <address> <address>")
+		fmt.Println("       geocode --serve <addr>")
+		fmt.Println("       geocode [--reverse] --repl")
 		os.Exit(1)
 	}
 
 	address := strings.Join(flag.Args(), " ")
 
+	if *reverse && *input == "" && *serveAddr == "" && !*explainMode && !*healthcheckMode && !*replMode {
+		if _, _, err := parseLatLng(address); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// List of providers
-	providers := []struct {
-		name  string
-		fn    func(string) (float64, float64, error)
-		isAPI bool
-		env   string
-	}{
-		{"google", geocodeGoogle, true, "GOOGLE_API_KEY"},
-		{"positionstack", geocodePositionstack, true, "POSITIONSTACK_KEY"},
-		{"opencage", geocodeOpenCage, true, "OPENCAGE_KEY"},
-		{"locationiq", geocodeLocationIQ, true, "LOCATIONIQ_KEY"},
-		{"mapquest", geocodeMapQuest, true, "MAPQUEST_KEY"},
-		{"osm", geocodeOSM, false, ""},
-	}
-
-	// Find selected provider
-	var selected *struct {
-		name  string
-		fn    func(string) (float64, float64, error)
-		isAPI bool
-		env   string
+	providers := geocode.Providers()
+
+	// A config file, or failing that $GEOLOOKER_PROVIDERS, replaces the
+	// default order and decides which providers are in play. --provider then
+	// only promotes one provider to the front, and only when given
+	// explicitly. --providers overrides all of them.
+	// Its consensus weights apply whichever list is in effect.
+	var configNames []string
+	var weights map[string]float64
+	if *configPath != "" {
+		var err error
+		configNames, weights, err = loadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	for _, p := range providers {
-		if p.name == *provider {
+	var defaultNames []string
+	switch {
+	case *providerList != "":
+	case *configPath != "":
+		defaultNames = configNames
+	case os.Getenv(providersEnv) != "":
+		names, err := parseProviderNames(providersEnv, os.Getenv(providersEnv))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defaultNames = names
+	}
+	if defaultNames != nil {
+		providers = providers[:0]
+		for _, name := range defaultNames {
+			p, _ := geocode.LookupProvider(name)
+			providers = append(providers, p)
+		}
+	}
+
+	// Find selected provider. An explicit --provider outside the default
+	// list is still tried first.
+	var selected *geocode.Provider
+	usePrimary := *providerList == "" && (defaultNames == nil || providerSet)
+	if usePrimary {
+		if p, ok := geocode.LookupProvider(*provider); ok {
 			selected = &p
-			break
 		}
 	}
 
 	// Warnings for invalid provider or missing API key
 	if selected == nil {
-		fmt.Fprintf(os.Stderr, "Warning: provider '%s' not recognized. Falling back to available providers.\n", *provider)
-	} else if selected.isAPI && os.Getenv(selected.env) == "" {
-		fmt.Fprintf(os.Stderr, "Warning: API key for provider '%s' not set in environment variable %s. Falling back to other providers.\n", selected.name, selected.env)
+		if usePrimary {
+			warnf("provider '%s' not recognized. Falling back to available providers.", *provider)
+		}
+	} else if selected.NeedsKey() && (geocode.Options{Keys: keys}).Key(*selected) == "" {
+		if *keyWarningFatal {
+			exitMissingKey(*selected)
+		}
+		warnf("API key for provider '%s' not set via --%s-key or environment variable %s. Falling back to other providers.", selected.Name, selected.Name, selected.KeyEnv)
 	}
 
 	// Reorder: selected first (if valid), then the rest
-	var ordered []struct {
-		name  string
-		fn    func(string) (float64, float64, error)
-		isAPI bool
-		env   string
+	var ordered []string
+	for _, p := range providers {
+		ordered = append(ordered, p.Name)
 	}
 	if selected != nil {
-		ordered = append(ordered, *selected)
+		ordered = preferProvider(ordered, selected.Name)
 	}
-	for _, p := range providers {
-		if selected == nil || p.name != selected.name {
-			ordered = append(ordered, p)
+	if *providerList != "" {
+		if names, err := parseProviderNames("--providers", *providerList); err == nil && *keyWarningFatal {
+			first, _ := geocode.LookupProvider(names[0])
+			if first.NeedsKey() && (geocode.Options{Keys: keys}).Key(first) == "" {
+				exitMissingKey(first)
+			}
+		}
+		var err error
+		ordered, err = parseProviderList(*providerList, keys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *explainMode {
+		explain(os.Stdout, ordered, geocode.Options{Keys: keys, RequireHTTPS: *requireHTTPS}, *reverse)
+		return
+	}
+
+	httpClient, err := geocode.NewHTTPClient(*proxy, *insecure)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := geocode.Options{
+		HTTPClient:          httpClient,
+		UserAgent:           *userAgent,
+		Providers:           ordered,
+		Keys:                keys,
+		Limit:               *limit,
+		Country:             *country,
+		Region:              *region,
+		FeatureType:         *featureType,
+		Bounds:              bounds,
+		Language:            *lang,
+		Timeout:             *timeout,
+		TotalTimeout:        *timeoutTotal,
+		Retries:             *retries,
+		MaxRetryAfter:       *maxRetryAfter,
+		OSMInterval:         time.Duration(float64(time.Second) / *osmRate),
+		QPS:                 *qps,
+		Race:                *race,
+		Raw:                 *raw,
+		FailFast:            *failFast,
+		FallbackOn:          fallbackClasses,
+		MinConfidence:       *minConfidence,
+		Require:             require,
+		ExpandAbbreviations: *normalize,
+		RejectNullIsland:    *rejectNullIsland,
+		RequireHTTPS:        *requireHTTPS,
+	}
+	if *seed != 0 {
+		opts.Rand = rand.New(rand.NewSource(*seed))
+	}
+	if *breakerThreshold > 0 {
+		opts.Breaker = geocode.NewCircuitBreaker(*breakerThreshold, *breakerWindow, *breakerCooldown)
+	}
+	if !quiet {
+		perRequest := *timeout
+		if *timeoutTotal > 0 {
+			perRequest = 0
+		}
+		opts.OnFailure = func(name string, err error) {
+			reportFailure(name, err, perRequest)
 		}
 	}
+	if *verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	ctx := context.Background()
+	if *serveAddr != "" || *input != "" || *inputCSV != "" {
+		// Long-running modes stop cleanly on SIGINT/SIGTERM: lookups are
+		// cancelled and finished output is flushed. A second signal kills
+		// the process as usual.
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		context.AfterFunc(ctx, stop)
+	}
 
-	// Try providers until one succeeds
-	for _, p := range ordered {
-		lat, lng, err := p.fn(address)
+	var cache *geocode.FileCache
+	if *cachePath != "" {
+		var err error
+		cache, err = geocode.OpenFileCache(*cachePath, *cacheTTL)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Provider %s failed: %v\n", p.name, err)
-			continue
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Cache = cache
+	}
+	saveCache := func() {
+		if cache == nil {
+			return
+		}
+		if err := cache.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not write cache: %v\n", err)
+		}
+	}
+
+	if *healthcheckMode {
+		if !healthCheck(ctx, os.Stdout, opts) {
+			os.Exit(1)
 		}
-		printJSON(p.name, address, lat, lng)
 		return
 	}
 
-	fmt.Fprintln(os.Stderr, "All providers failed")
-	os.Exit(1)
+	if *serveAddr != "" {
+		if *cacheSize > 0 {
+			mem := geocode.NewMemoryCache(*cacheSize)
+			if opts.Cache != nil {
+				opts.Cache = geocode.TieredCache(mem, opts.Cache)
+			} else {
+				opts.Cache = mem
+			}
+		}
+		err := serve(ctx, *serveAddr, opts, annotate)
+		saveCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if compareNames != nil {
+		copts := opts
+		copts.Providers = compareNames
+		answers, err := geocode.QueryAll(ctx, address, copts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveCache()
+		res := compare(address, answers)
+		for _, a := range answers {
+			if a.Result != nil {
+				annotate.applyOne(a.Result)
+				roundResult(a.Result, *round)
+			}
+		}
+		err = writeOutput(*output, func(w io.Writer) error {
+			return writeIndented(w, res)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if res.DistanceM == nil {
+			fmt.Fprintln(os.Stderr, "Comparison incomplete: a provider failed")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *selectBy != "" {
+		answers, err := geocode.QueryAll(ctx, address, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveCache()
+		for _, a := range answers {
+			if a.Result != nil {
+				annotate.applyOne(a.Result)
+				roundResult(a.Result, *round)
+			}
+		}
+		sel, ok := geocode.SelectAnswer(answers, *selectBy, *clusterDist)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "All providers failed")
+			os.Exit(1)
+		}
+		err = writeOutput(*output, func(w io.Writer) error {
+			return writeIndented(w, sel)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *consensusMode {
+		answers, err := geocode.QueryAll(ctx, address, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveCache()
+		for _, a := range answers {
+			if a.Result != nil {
+				annotate.applyOne(a.Result)
+			}
+		}
+		res, ok := geocode.WeightedConsensus(address, answers, weights)
+		res.Clusters = geocode.ClusterAnswers(res.Providers, *clusterDist)
+		res.Latitude = roundCoord(res.Latitude, *round)
+		res.Longitude = roundCoord(res.Longitude, *round)
+		for _, a := range res.Providers {
+			if a.Result != nil {
+				roundResult(a.Result, *round)
+			}
+		}
+		err = writeOutput(*output, func(w io.Writer) error {
+			if *format == "table" {
+				return printConsensusTable(w, res)
+			}
+			return writeIndented(w, res)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "All providers failed")
+			os.Exit(1)
+		}
+		if n := len(res.Contributors); n < *minQuorum {
+			msg := fmt.Sprintf("low confidence: only %d of %d providers succeeded, --min-quorum is %d", n, len(res.Providers), *minQuorum)
+			if *quorumWarn {
+				warnf("%s", msg)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// With --session, autocomplete lines share a Places session until a
+	// suggestion is picked by its number (1 for the first); resolving it
+	// ends the session and the next line starts a new one.
+	var suggestions []geocode.GeocodeResult
+	if *session {
+		opts.SessionToken = geocode.NewSessionToken()
+	}
+
+	// Try providers until one succeeds, or all at once with --race
+	lookup := func(query string) ([]geocode.GeocodeResult, error) {
+		if *autocomplete && *session {
+			if n, err := strconv.Atoi(query); err == nil && n >= 1 && n <= len(suggestions) {
+				chosen := suggestions[n-1]
+				suggestions = nil
+				if chosen.PlaceID == "" {
+					// Suggestions from other providers have coordinates.
+					return []geocode.GeocodeResult{chosen}, nil
+				}
+				res, err := geocode.GeocodePlace(ctx, chosen.PlaceID, opts)
+				opts.SessionToken = geocode.NewSessionToken()
+				if err != nil {
+					return nil, err
+				}
+				res.Address = chosen.Formatted
+				return []geocode.GeocodeResult{res}, nil
+			}
+			results, err := geocode.Autocomplete(ctx, query, opts)
+			suggestions = results
+			return results, err
+		}
+		if *autocomplete {
+			return geocode.Autocomplete(ctx, query, opts)
+		}
+		if useStructured {
+			return geocode.GeocodeStructured(ctx, structured, opts)
+		}
+		if !*reverse {
+			return geocode.GeocodeMany(ctx, query, opts)
+		}
+		lat, lng, err := parseLatLng(query)
+		if err != nil {
+			return nil, err
+		}
+		res, err := geocode.Reverse(ctx, lat, lng, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []geocode.GeocodeResult{res}, nil
+	}
+	resolve := func(query string) ([]geocode.GeocodeResult, error) {
+		results, err := lookup(query)
+		if err != nil {
+			return nil, err
+		}
+		annotate.apply(results)
+		if *elevation {
+			addElevation(ctx, results, opts)
+		}
+		if *timezone {
+			addTimeZone(ctx, results, opts)
+		}
+		if *w3w {
+			addWords(ctx, results, opts)
+		}
+		return results, nil
+	}
+	// Batch modes geocode repeated addresses once.
+	batchKey := func(line string) string {
+		if *reverse {
+			// Pairs written differently but equal to six decimals, like
+			// the cache key, are looked up once.
+			if lat, lng, err := parseLatLng(line); err == nil {
+				return fmt.Sprintf("%.6f,%.6f", lat, lng)
+			}
+			return line
+		}
+		return geocode.QueryKey(line, *normalize)
+	}
+
+	if distanceMode {
+		res, err := distance(flag.Arg(1), flag.Arg(2), resolve)
+		saveCache()
+		if errors.Is(err, geocode.ErrAllFailed) {
+			fmt.Fprintln(os.Stderr, "All providers failed")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		roundResult(&res.From, *round)
+		roundResult(&res.To, *round)
+		err = writeOutput(*output, func(w io.Writer) error {
+			return writeIndented(w, res)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *inputCSV != "" {
+		header, rows, col, err := readCSV(*inputCSV, *addressColumn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		err = writeOutput(*output, func(w io.Writer) error {
+			return geocodeCSV(ctx, w, header, rows, col, *concurrency, *round, batchKey, resolve)
+		})
+		saveCache()
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "Interrupted: output is incomplete")
+			os.Exit(130)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *replMode {
+		prompt := ""
+		if isTerminal(os.Stdin) {
+			prompt = replPrompt
+		}
+		err := repl(os.Stdin, os.Stdout, prompt, resolve, func(w io.Writer, results []geocode.GeocodeResult) error {
+			roundResults(results, *round)
+			return printResults(w, results, *format, *autocomplete || *limit > 1 && !*reverse, false, fields)
+		})
+		saveCache()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *input != "" {
+		lines, err := readLines(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// JSON lines are written as each input finishes; other formats
+		// need the whole batch first.
+		err = writeOutput(*output, func(w io.Writer) error {
+			if *format == "jsonl" {
+				var werr error
+				err := geocodeBatch(ctx, lines, *concurrency, batchKey, resolve, func(results []geocode.GeocodeResult) {
+					if werr == nil {
+						roundResults(results, *round)
+						werr = printJSONLines(w, results, fields)
+					}
+				})
+				if werr != nil {
+					return werr
+				}
+				return err
+			}
+			var results []geocode.GeocodeResult
+			err := geocodeBatch(ctx, lines, *concurrency, batchKey, resolve, func(r []geocode.GeocodeResult) {
+				roundResults(r, *round)
+				results = append(results, r...)
+			})
+			if perr := printResults(w, results, *format, true, true, fields); perr != nil {
+				return perr
+			}
+			return err
+		})
+		saveCache()
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "Interrupted: output is incomplete")
+			os.Exit(130)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	results, err := resolve(address)
+	saveCache()
+	if err != nil && (*format == "json" || *format == "jsonl") {
+		// Exit nonzero as usual, but leave a parseable record of why.
+		if werr := writeOutput(*output, func(w io.Writer) error { return printFailure(w, err, *format) }); werr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", werr)
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) && *timeoutTotal > 0 {
+		fmt.Fprintf(os.Stderr, "Error: lookup timed out after %s (--timeout-total): %v\n", *timeoutTotal, err)
+		os.Exit(1)
+	}
+	if errors.Is(err, geocode.ErrAllFailed) {
+		fmt.Fprintln(os.Stderr, "All providers failed")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	roundResults(results, *round)
+	err = writeOutput(*output, func(w io.Writer) error {
+		return printResults(w, results, *format, *autocomplete || *limit > 1 && !*reverse, false, fields)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Output formats -----------
+
+// writeOutput calls write with stdout, or with the file at path (created or
+// truncated) when path is set.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printResults writes results to w in the requested output format.
+// batch marks output from --input, which always carries per-row errors.
+// fields, if set, restricts json, jsonl and csv output to those fields; see
+// parseOutputFields.
+func printResults(w io.Writer, results []geocode.GeocodeResult, format string, asArray, batch bool, fields []string) error {
+	switch format {
+	case "json":
+		return printJSON(w, results, asArray, fields)
+	case "csv":
+		return printCSV(w, results, batch, fields)
+	case "geojson":
+		return writeIndented(w, toGeoJSON(results))
+	case "kml":
+		return writeKML(w, toKML(results))
+	case "jsonl":
+		return printJSONLines(w, results, fields)
+	case "table":
+		if !asArray && !batch {
+			return printKeyValues(w, results[0])
+		}
+		return printTable(w, results, batch)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// printJSON writes a single result as an object, or all results as an array
+// when asArray is set.
+func printJSON(w io.Writer, results []geocode.GeocodeResult, asArray bool, fields []string) error {
+	if fields != nil {
+		projected, err := projectResults(results, fields)
+		if err != nil {
+			return err
+		}
+		if asArray {
+			return writeIndented(w, projected)
+		}
+		return writeIndented(w, projected[0])
+	}
+	if asArray {
+		return writeIndented(w, results)
+	}
+	return writeIndented(w, results[0])
+}
+
+// printJSONLines writes one compact JSON object per result, so batch output
+// can be consumed as a stream and failed inputs appear as rows with an
+// error field.
+func printJSONLines(w io.Writer, results []geocode.GeocodeResult, fields []string) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		var v any = r
+		if fields != nil {
+			o, err := projectResult(r, fields)
+			if err != nil {
+				return err
+			}
+			v = o
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failureOutput takes the place of results in JSON output when a lookup
+// fails, so machine consumers can parse the failure instead of stderr.
+type failureOutput struct {
+	Error string `json:"error"`
+	// Attempts lists each provider's failure when all of them failed.
+	Attempts []geocode.Attempt `json:"attempts,omitempty"`
+}
+
+// newFailureOutput describes err, including per-provider attempts when it
+// is a *geocode.AllFailedError.
+func newFailureOutput(err error) failureOutput {
+	out := failureOutput{Error: err.Error()}
+	var failed *geocode.AllFailedError
+	if errors.As(err, &failed) {
+		out.Attempts = failed.Attempts
+	}
+	return out
+}
+
+// printFailure writes err as a failureOutput in the json or jsonl format.
+func printFailure(w io.Writer, err error, format string) error {
+	if format == "jsonl" {
+		return json.NewEncoder(w).Encode(newFailureOutput(err))
+	}
+	return writeIndented(w, newFailureOutput(err))
+}
+
+// writeIndented writes v as indented JSON followed by a newline.
+func writeIndented(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// csvColumn is one CSV output column. Optional columns are only written
+// when at least one result has a value for them.
+type csvColumn struct {
+	name     string
+	value    func(geocode.GeocodeResult) string
+	optional bool
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+var csvColumns = []csvColumn{
+	{"provider", func(r geocode.GeocodeResult) string { return r.Provider }, false},
+	{"address", func(r geocode.GeocodeResult) string { return r.Address }, false},
+	{"latitude", func(r geocode.GeocodeResult) string { return formatFloat(r.Latitude) }, false},
+	{"longitude", func(r geocode.GeocodeResult) string { return formatFloat(r.Longitude) }, false},
+	{"latitude_dms", func(r geocode.GeocodeResult) string { return r.LatitudeDMS }, true},
+	{"longitude_dms", func(r geocode.GeocodeResult) string { return r.LongitudeDMS }, true},
+	{"utm", func(r geocode.GeocodeResult) string {
+		if r.UTM == nil {
+			return ""
+		}
+		return r.UTM.String()
+	}, true},
+	{"geohash", func(r geocode.GeocodeResult) string { return r.Geohash }, true},
+	{"plus_code", func(r geocode.GeocodeResult) string { return r.PlusCode }, true},
+	{"elevation", func(r geocode.GeocodeResult) string {
+		if r.Elevation == nil {
+			return ""
+		}
+		return formatFloat(*r.Elevation)
+	}, true},
+	{"match_type", func(r geocode.GeocodeResult) string { return r.MatchType }, true},
+	{"timezone_id", func(r geocode.GeocodeResult) string { return r.TimeZoneID }, true},
+	{"utc_offset", func(r geocode.GeocodeResult) string { return r.UTCOffset }, true},
+	{"words", func(r geocode.GeocodeResult) string { return r.Words }, true},
+}
+
+// printCSV writes a header row followed by one row per result. withError
+// adds an error column, used by batch mode to report failed addresses.
+// fields, if set, picks the columns and their order instead, with the
+// error column still added for batch mode.
+func printCSV(out io.Writer, results []geocode.GeocodeResult, withError bool, fields []string) error {
+	errorColumn := csvColumn{"error", func(r geocode.GeocodeResult) string { return r.Error }, false}
+	var cols []csvColumn
+	for _, c := range csvColumns {
+		if fields == nil && (!c.optional || anyValue(results, c.value)) {
+			cols = append(cols, c)
+		}
+	}
+	for _, name := range fields {
+		if name == "error" {
+			cols = append(cols, errorColumn)
+			withError = false
+			continue
+		}
+		for _, c := range csvColumns {
+			if c.name == name {
+				cols = append(cols, c)
+			}
+		}
+	}
+	if withError {
+		cols = append(cols, errorColumn)
+	}
+
+	w := csv.NewWriter(out)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
+	}
+	w.Write(header)
+	for _, r := range results {
+		row := make([]string, len(cols))
+		for i, c := range cols {
+			row[i] = c.value(r)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func anyValue(results []geocode.GeocodeResult, value func(geocode.GeocodeResult) string) bool {
+	for _, r := range results {
+		if value(r) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// roundCoord rounds a coordinate to n decimal places; a negative n keeps
+// full precision.
+func roundCoord(f float64, n int) float64 {
+	if n < 0 {
+		return f
+	}
+	p := math.Pow10(n)
+	return math.Round(f*p) / p
+}
+
+// roundResult rounds the coordinates of r in place for output. It must run
+// after any computation that needs full precision.
+func roundResult(r *geocode.GeocodeResult, n int) {
+	r.Latitude = roundCoord(r.Latitude, n)
+	r.Longitude = roundCoord(r.Longitude, n)
+}
+
+func roundResults(results []geocode.GeocodeResult, n int) {
+	for i := range results {
+		roundResult(&results[i], n)
+	}
+}
+
+// ----------- Table -----------
+
+// tableColumns are the columns of --format table. Only provider, latitude
+// and longitude are always shown; the rest appear when some result has them.
+var tableColumns = []csvColumn{
+	{"provider", func(r geocode.GeocodeResult) string { return r.Provider }, false},
+	{"latitude", func(r geocode.GeocodeResult) string { return formatFloat(r.Latitude) }, false},
+	{"longitude", func(r geocode.GeocodeResult) string { return formatFloat(r.Longitude) }, false},
+	{"confidence", func(r geocode.GeocodeResult) string {
+		if r.Confidence == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(r.Confidence, 'f', 2, 64)
+	}, true},
+	{"formatted", func(r geocode.GeocodeResult) string { return r.Formatted }, true},
+}
+
+// printTable writes results as an aligned text table. withError adds the
+// input address and error columns used by batch mode.
+func printTable(out io.Writer, results []geocode.GeocodeResult, withError bool) error {
+	var cols []csvColumn
+	if withError {
+		cols = append(cols, csvColumns[1]) // address
+	}
+	for _, c := range tableColumns {
+		if !c.optional || anyValue(results, c.value) {
+			cols = append(cols, c)
+		}
+	}
+	if withError {
+		cols = append(cols, csvColumn{"error", func(r geocode.GeocodeResult) string { return r.Error }, false})
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for i, c := range cols {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, strings.ToUpper(c.name))
+	}
+	fmt.Fprintln(tw)
+	for _, r := range results {
+		for i, c := range cols {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, c.value(r))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// printKeyValues writes a single result as a two-column key/value table,
+// skipping empty fields.
+func printKeyValues(out io.Writer, r geocode.GeocodeResult) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range csvColumns {
+		if v := c.value(r); v != "" {
+			fmt.Fprintf(tw, "%s\t%s\n", c.name, v)
+		}
+	}
+	for _, c := range tableColumns[3:] {
+		if v := c.value(r); v != "" {
+			fmt.Fprintf(tw, "%s\t%s\n", c.name, v)
+		}
+	}
+	return tw.Flush()
+}
+
+// printConsensusTable writes one row per provider answer followed by the
+// consensus coordinate.
+func printConsensusTable(out io.Writer, res geocode.ConsensusResult) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tLATITUDE\tLONGITUDE\tCONFIDENCE\tERROR")
+	for _, a := range res.Providers {
+		if a.Result == nil {
+			fmt.Fprintf(tw, "%s\t\t\t\t%s\n", a.Provider, a.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", a.Provider, formatFloat(a.Result.Latitude), formatFloat(a.Result.Longitude),
+			tableColumns[3].value(*a.Result))
+	}
+	note := fmt.Sprintf("max distance %.0f m, %d of %d providers", res.MaxDistance, len(res.Contributors), len(res.Providers))
+	if res.Weights != nil {
+		var ws []string
+		for _, name := range res.Contributors {
+			ws = append(ws, fmt.Sprintf("%s=%g", name, res.Weights[name]))
+		}
+		note += ", weighted " + strings.Join(ws, " ")
+	}
+	fmt.Fprintf(tw, "consensus\t%s\t%s\t\t%s\n", formatFloat(res.Latitude), formatFloat(res.Longitude), note)
+	return tw.Flush()
+}
+
+// ----------- GeoJSON -----------
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONPoint      `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // [lng, lat]
+}
+
+// toGeoJSON converts results into a FeatureCollection of Point features.
+func toGeoJSON(results []geocode.GeocodeResult) GeoJSONFeatureCollection {
+	fc := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		fc.Features = append(fc.Features, GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{r.Longitude, r.Latitude},
+			},
+			Properties: map[string]string{
+				"provider": r.Provider,
+				"address":  r.Address,
+			},
+		})
+	}
+	return fc
+}
+
+// ----------- KML -----------
+
+type KML struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document KMLDocument `xml:"Document"`
+}
+
+type KMLDocument struct {
+	Placemarks []KMLPlacemark `xml:"Placemark"`
+}
+
+type KMLPlacemark struct {
+	Name        string   `xml:"name"`
+	Description string   `xml:"description,omitempty"`
+	Point       KMLPoint `xml:"Point"`
+}
+
+type KMLPoint struct {
+	Coordinates string `xml:"coordinates"` // lng,lat,altitude
+}
+
+// toKML converts results into a single KML document with one placemark per
+// result, named after the address. Failed lookups are omitted.
+func toKML(results []geocode.GeocodeResult) KML {
+	doc := KML{Xmlns: "http://www.opengis.net/kml/2.2"}
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, KMLPlacemark{
+			Name:        r.Address,
+			Description: r.Provider,
+			Point:       KMLPoint{Coordinates: formatFloat(r.Longitude) + "," + formatFloat(r.Latitude) + ",0"},
+		})
+	}
+	return doc
+}
+
+func writeKML(w io.Writer, doc KML) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- REPL -----------
+
+// replPrompt is shown before each line when stdin is a terminal.
+const replPrompt = "geolooker> "
+
+// isTerminal reports whether f is an interactive character device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// repl reads addresses from in one line at a time until EOF or "quit",
+// resolving each and writing the results with print. A failed lookup is
+// reported on stderr and the loop carries on. The prompt, if non-empty, goes
+// to stderr so out only ever holds results.
+func repl(in io.Reader, out io.Writer, prompt string, resolve func(string) ([]geocode.GeocodeResult, error), print func(io.Writer, []geocode.GeocodeResult) error) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(os.Stderr, prompt)
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		results, err := resolve(line)
+		switch {
+		case errors.Is(err, geocode.ErrAllFailed):
+			fmt.Fprintln(os.Stderr, "All providers failed")
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		default:
+			if err := print(out, results); err != nil {
+				return err
+			}
+		}
+	}
+	if prompt != "" {
+		fmt.Fprintln(os.Stderr)
+	}
+	return scanner.Err()
+}
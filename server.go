@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fasoulas/geolooker/geocode"
+)
+
+// ----------- Server mode -----------
+
+// server exposes the same lookup chain as the CLI over HTTP.
+type server struct {
+	opts     geocode.Options
+	annotate annotations
+	metrics  *geocode.Metrics
+}
+
+// serverDrainTimeout is how long a shutting-down server waits for in-flight
+// requests before closing their connections.
+const serverDrainTimeout = 15 * time.Second
+
+// serve listens on addr until the server fails or ctx is cancelled. On
+// cancellation it stops accepting connections and lets in-flight requests
+// finish for up to serverDrainTimeout.
+func serve(ctx context.Context, addr string, opts geocode.Options, annotate annotations) error {
+	s := &server{opts: opts, annotate: annotate, metrics: &geocode.Metrics{}}
+	s.opts.Metrics = s.metrics
+	mux := http.NewServeMux()
+	mux.HandleFunc("/geocode", s.handleGeocode)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Listening on %s\n", addr)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), serverDrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %v", err)
+	}
+	return nil
+}
+
+// handleGeocode serves GET /geocode?address=...&provider=...
+func (s *server) handleGeocode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		writeError(w, http.StatusBadRequest, "missing address parameter")
+		return
+	}
+
+	opts := s.opts
+	if name := r.URL.Query().Get("provider"); name != "" {
+		if _, ok := geocode.LookupProvider(name); !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown provider %q", name))
+			return
+		}
+		opts.Providers = preferProvider(opts.Providers, name)
+	}
+
+	res, err := geocode.Geocode(r.Context(), address, opts)
+	if errors.Is(err, geocode.ErrAllFailed) {
+		writeJSON(w, http.StatusBadGateway, newFailureOutput(err))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.annotate.applyOne(&res)
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleMetrics serves provider metrics in the Prometheus text format.
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.WritePrometheus(w)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// ----------- Version -----------
+
+// Build information, set at link time with e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+//
+// Empty values are filled from the module build info where possible.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// printVersion writes the version, commit and build date to w.
+func printVersion(w io.Writer) {
+	v, c, d := version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && c == "":
+				c = s.Value
+			case s.Key == "vcs.time" && d == "":
+				d = s.Value
+			}
+		}
+	}
+	fmt.Fprintf(w, "geolooker %s\ncommit: %s\nbuilt:  %s\n", orDefault(v, "dev"), orDefault(c, "unknown"), orDefault(d, "unknown"))
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}